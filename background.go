@@ -0,0 +1,155 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	"github.com/jezek/xgbutil/xgraphics"
+	xdraw "golang.org/x/image/draw"
+)
+
+// BgMode defines how background image covers the bar.
+type BgMode uint8
+
+const (
+	STRETCH BgMode = iota
+	TILE
+)
+
+var bgModeNames = map[BgMode]string{
+	STRETCH: "stretch",
+	TILE:    "tile",
+}
+
+func (m *BgMode) String() string {
+	return bgModeNames[*m]
+}
+
+func (m *BgMode) Set(value string) error {
+	for mode, name := range bgModeNames {
+		if name == value {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid background image mode `%s`", value)
+}
+
+// loadImage decodes PNG, JPEG or GIF image from the given path.
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(expandPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode image `%s`: %s", path, err)
+	}
+	return img, nil
+}
+
+// bgKey identifies cached background.
+type bgKey struct {
+	size  image.Point
+	color xgraphics.BGRA
+}
+
+// background returns background of given size, with BgImage stretched
+// or tiled over given color. With Dither, it is composed in 16 bits
+// per channel and dithered down. Result is cached per size and color,
+// so it should not be modified.
+func (b *Bar) background(size image.Point, color *xgraphics.BGRA) *xgraphics.Image {
+	key := bgKey{size, *color}
+	if bg, ok := b.bgCache[key]; ok {
+		return bg
+	}
+
+	rect := image.Rectangle{Max: size}
+	var dst draw.Image = image.NewRGBA(rect)
+	if b.Dither {
+		dst = image.NewRGBA64(rect)
+	}
+	draw.Draw(dst, rect, image.NewUniform(color), image.Point{}, draw.Src)
+
+	src := b.BgImage.Bounds()
+	switch b.BgImageMode {
+	case TILE:
+		for y := 0; y < size.Y; y += src.Dy() {
+			for x := 0; x < size.X; x += src.Dx() {
+				tile := image.Rect(x, y, x+src.Dx(), y+src.Dy())
+				draw.Draw(dst, tile, b.BgImage, src.Min, draw.Over)
+			}
+		}
+	default:
+		xdraw.ApproxBiLinear.Scale(dst, rect, b.BgImage, src, draw.Over, nil)
+	}
+
+	bg := &xgraphics.Image{
+		Pix:    make([]uint8, 4*size.X*size.Y),
+		Stride: 4 * size.X,
+		Rect:   rect,
+	}
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			if !b.Dither {
+				bg.Set(x, y, dst.At(x, y))
+				continue
+			}
+			// Background color is opaque, so the result is as well.
+			r, g, bl, _ := dst.At(x, y).RGBA()
+			bg.SetBGRA(x, y, xgraphics.BGRA{
+				B: dither(bl, x, y), G: dither(g, x, y), R: dither(r, x, y), A: 0xFF,
+			})
+		}
+	}
+
+	if b.bgCache == nil {
+		b.bgCache = map[bgKey]*xgraphics.Image{}
+	}
+	b.bgCache[key] = bg
+	return bg
+}
+
+// bayer4 is a threshold map for ordered dithering.
+var bayer4 = [4][4]uint32{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// dither reduces 16 bit channel value to 8 bits, rounding it up or down
+// depending on position x, y, so that gradients do not band.
+func dither(v uint32, x, y int) uint8 {
+	threshold := (2*bayer4[y%4][x%4] + 1) * 0x101 / 32
+	return uint8((v + threshold) / 0x101)
+}
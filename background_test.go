@@ -0,0 +1,180 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/jezek/xgbutil/xgraphics"
+	"golang.org/x/image/font/inconsolata"
+)
+
+func TestBgModeSet(t *testing.T) {
+	tests := []struct {
+		input  string
+		output BgMode
+		err    error
+	}{
+		{"stretch", STRETCH, nil},
+		{"tile", TILE, nil},
+		{"wrongo", STRETCH, fmt.Errorf("invalid background image mode `wrongo`")},
+	}
+
+	for i, test := range tests {
+		var mode BgMode
+
+		err := mode.Set(test.input)
+
+		assertEqual(t, test.input, test.output, mode, "BgModeSet", i)
+		assertEqualError(t, test.err, err, "BgModeSet", i)
+	}
+}
+
+func TestBarBackground(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	single := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	single.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+	double := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	double.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+	double.Set(1, 0, color.RGBA{B: 0xFF, A: 0xFF})
+	transparent := image.NewRGBA(image.Rect(0, 0, 1, 1))
+
+	tests := []struct {
+		img    image.Image
+		mode   BgMode
+		dither bool
+		output []xgraphics.BGRA
+	}{
+		{single, STRETCH, false, []xgraphics.BGRA{red, red, red, red, red}},
+		{single, TILE, false, []xgraphics.BGRA{red, red, red, red, red}},
+		{double, TILE, false, []xgraphics.BGRA{red, blue, red, blue, red}},
+		{transparent, STRETCH, false, []xgraphics.BGRA{black, black, black, black, black}},
+		{single, STRETCH, true, []xgraphics.BGRA{red, red, red, red, red}},
+		{double, TILE, true, []xgraphics.BGRA{red, blue, red, blue, red}},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{Background: colors{&black}}
+		bar.BgImage = test.img
+		bar.BgImageMode = test.mode
+		bar.Dither = test.dither
+
+		bg := bar.background(image.Pt(5, 2), &black)
+
+		for y := 0; y < 2; y++ {
+			for x, color := range test.output {
+				assertEqual(t, image.Pt(x, y), color, bg.At(x, y), "BarBackground", i)
+			}
+		}
+		// Same size is served from cache.
+		assertEqual(t, nil, bg, bar.background(image.Pt(5, 2), &black), "BarBackground", i)
+	}
+}
+
+func TestBarCompose_bgImage(t *testing.T) {
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+
+	bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+	bar.BgImage = img
+	bar.createCanvases()
+
+	// Piece without own background shows the image through.
+	pieces := bar.resolve([]*TextPiece{{Text: " "}, {Text: " ", Background: &blue}}, 1)
+	bar.compose(0, pieces)
+
+	for cell, color := range []xgraphics.BGRA{red, blue, red, red} {
+		assertEqual(t, cell, color, bar.canvases[0].img.At(cell*8+4, 0), "BarCompose_bgImage", 0)
+	}
+}
+
+func TestBarCompose_bgImageUrgent(t *testing.T) {
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+	purple := xgraphics.BGRA{B: 0x40, G: 0x00, R: 0xBF, A: 0xFF}
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+
+	tests := []struct {
+		period time.Duration
+		pixels []xgraphics.BGRA
+	}{
+		{6 * time.Second, []xgraphics.BGRA{purple, red}},
+		{0, []xgraphics.BGRA{blue, red}},
+	}
+
+	defer func(orig func() time.Time) { timeNow = orig }(timeNow)
+	timeNow = func() time.Time { return time.Unix(1, 0) }
+	for i, test := range tests {
+		bar := &Bar{
+			Foreground: colors{&red},
+			Background: colors{&blue},
+			Fonts:      fonts{inconsolata.Regular8x16},
+			Options:    Options{UrgentColor: &blue, UrgentPeriod: test.period, BgImage: img},
+		}
+		createFake(bar, nil, 32)
+		bar.Draw([]*TextPiece{{Text: " ", Urgent: true}, {Text: " "}})
+
+		// Urgent piece pulses over the image, not over the background color.
+		canvas := bar.canvases[0].img
+		pixels := []xgraphics.BGRA{canvas.At(4, 8).(xgraphics.BGRA), canvas.At(12, 8).(xgraphics.BGRA)}
+		assertEqual(t, test.period, test.pixels, pixels, "BarCompose_bgImageUrgent", i)
+	}
+}
+
+func TestDither(t *testing.T) {
+	tests := []struct {
+		input  uint32
+		output [256]int
+	}{
+		{0x0000, [256]int{0: 16}},
+		{0xFFFF, [256]int{255: 16}},
+		{0x8080, [256]int{128: 16}},
+		// Halfway between 128 and 129.
+		{0x8100, [256]int{128: 8, 129: 8}},
+		// Quarter of the way from 128 to 129.
+		{0x80C0, [256]int{128: 12, 129: 4}},
+	}
+
+	for i, test := range tests {
+		var actual [256]int
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				actual[dither(test.input, x, y)]++
+			}
+		}
+
+		assertEqual(t, test.input, test.output, actual, "Dither", i)
+	}
+}
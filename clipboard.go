@@ -0,0 +1,146 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/xevent"
+	"github.com/jezek/xgbutil/xprop"
+)
+
+// selections are selections clicked pieces get copied to.
+var selections = []string{"CLIPBOARD", "PRIMARY"}
+
+// clickedCopy returns text to copy on clicking pt of given screen,
+// i.e. Copy of the piece drawn there, or its text, if it has none.
+func (b *Bar) clickedCopy(screen uint, pt image.Point) (string, bool) {
+	c := b.canvases[screen]
+	i := c.spanAt(pt)
+	if i == -1 {
+		return "", false
+	}
+	piece := c.spanPieces[i]
+	if piece.Copy != "" {
+		return piece.Copy, true
+	}
+	return piece.Text, piece.Text != ""
+}
+
+// listenClicks makes left clicks on window of given screen copy
+// pieces they are over, with the window serving the copied text.
+// Window must already listen to windowEvents.
+func (b *Bar) listenClicks(screen int) {
+	win := b.Windows[screen]
+	xevent.ButtonPressFun(func(_ *xgbutil.XUtil, ev xevent.ButtonPressEvent) {
+		if ev.Detail != xproto.ButtonIndex1 {
+			return
+		}
+		pt := image.Pt(int(ev.EventX), int(ev.EventY))
+		if text, ok := b.clickedCopy(uint(screen), pt); ok {
+			b.copyText(win.Id, text, ev.Time)
+		}
+	}).Connect(b.X, win.Id)
+	xevent.SelectionRequestFun(func(_ *xgbutil.XUtil, ev xevent.SelectionRequestEvent) {
+		b.serveSelection(ev.SelectionRequestEvent)
+	}).Connect(b.X, win.Id)
+	xevent.SelectionClearFun(func(_ *xgbutil.XUtil, ev xevent.SelectionClearEvent) {
+		// Someone else copied something, so there is nothing to serve anymore.
+		delete(b.copied, ev.Selection)
+	}).Connect(b.X, win.Id)
+}
+
+// copyText makes win owner of the selections, with text as their contents.
+func (b *Bar) copyText(win xproto.Window, text string, time xproto.Timestamp) {
+	if b.copied == nil {
+		b.copied = map[xproto.Atom]string{}
+	}
+	for _, name := range selections {
+		selection, err := xprop.Atm(b.X, name)
+		if err != nil {
+			logf(ERROR, "Could not copy to `%s`: %s", name, err)
+			continue
+		}
+		b.copied[selection] = text
+		xproto.SetSelectionOwner(b.X.Conn(), win, selection, time)
+	}
+	logf(DEBUG, "Copied `%s`", text)
+}
+
+// ownSelections makes win owner of the selections still copied to,
+// as they are lost together with windows which owned them,
+// e.g. when bars get recreated.
+func (b *Bar) ownSelections(win xproto.Window) {
+	for selection := range b.copied {
+		xproto.SetSelectionOwner(b.X.Conn(), win, selection, xproto.TimeCurrentTime)
+	}
+}
+
+// serveSelection answers request of a client pasting the copied text,
+// either with the text itself, or with targets it can be converted to.
+func (b *Bar) serveSelection(ev *xproto.SelectionRequestEvent) {
+	targets, _ := xprop.Atm(b.X, "TARGETS")
+	utf8String, _ := xprop.Atm(b.X, "UTF8_STRING")
+
+	property := ev.Property
+	if property == xproto.AtomNone {
+		// Obsolete clients expect the target to be used as property.
+		property = ev.Target
+	}
+	switch ev.Target {
+	case targets:
+		atoms := []xproto.Atom{targets, utf8String, xproto.AtomString}
+		data := make([]byte, 4*len(atoms))
+		for i, atom := range atoms {
+			xgb.Put32(data[i*4:], uint32(atom))
+		}
+		xproto.ChangeProperty(
+			b.X.Conn(), xproto.PropModeReplace, ev.Requestor, property,
+			xproto.AtomAtom, 32, uint32(len(atoms)), data,
+		)
+	case utf8String, xproto.AtomString:
+		text, ok := b.copied[ev.Selection]
+		if !ok {
+			property = xproto.AtomNone
+			break
+		}
+		xproto.ChangeProperty(
+			b.X.Conn(), xproto.PropModeReplace, ev.Requestor, property,
+			ev.Target, 8, uint32(len(text)), []byte(text),
+		)
+	default:
+		property = xproto.AtomNone
+	}
+
+	notify := xproto.SelectionNotifyEvent{
+		Time:      ev.Time,
+		Requestor: ev.Requestor,
+		Selection: ev.Selection,
+		Target:    ev.Target,
+		Property:  property,
+	}
+	xproto.SendEvent(b.X.Conn(), false, ev.Requestor, xproto.EventMaskNoEvent, string(notify.Bytes()))
+}
@@ -0,0 +1,54 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestBarClickedCopy(t *testing.T) {
+	bar := newTestBar(t, &Geometry{Width: 64, Height: 16})
+	bar.ClickCopy = true
+	bar.compose(0, bar.resolve([]*TextPiece{
+		{Text: "ip", Copy: "10.0.0.1"}, {Text: "up"},
+	}, 1))
+
+	tests := []struct {
+		input  image.Point
+		output string
+		ok     bool
+	}{
+		{image.Pt(4, 8), "10.0.0.1", true},
+		{image.Pt(12, 8), "10.0.0.1", true},
+		{image.Pt(20, 8), "up", true},
+		{image.Pt(40, 8), "", false},
+	}
+
+	for i, test := range tests {
+		output, ok := bar.clickedCopy(0, test.input)
+
+		assertEqual(t, test.input, test.output, output, "BarClickedCopy", i)
+		assertEqual(t, test.input, test.ok, ok, "BarClickedCopy", i)
+	}
+}
@@ -0,0 +1,49 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// clockInterval is how often the built-in clock is drawn.
+const clockInterval = time.Second
+
+// parseClock parses layout of the built-in clock into pieces,
+// with texts being time layouts. Markup is parsed before any time gets
+// formatted, so that e.g. font indices are not taken for layout elements.
+func parseClock(parser Parser, layout string) []*TextPiece {
+	return parser.Scan(strings.NewReader(layout))
+}
+
+// clockText returns copies of clock pieces, with texts formatted as t.
+func clockText(pieces []*TextPiece, t time.Time) []*TextPiece {
+	text := make([]*TextPiece, len(pieces))
+	for i, piece := range pieces {
+		formatted := *piece
+		formatted.Text = t.Format(piece.Text)
+		text[i] = &formatted
+	}
+	return text
+}
@@ -0,0 +1,54 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockText(t *testing.T) {
+	now := time.Date(2022, time.March, 7, 9, 5, 3, 0, time.UTC)
+	tests := []struct {
+		layout string
+		output []*TextPiece
+	}{
+		{"15:04", []*TextPiece{{Text: "09:05"}}},
+		{"{F1Jan 2 15:04:05}", []*TextPiece{{Text: "Mar 7 09:05:03", Font: 1}}},
+		{"15h{AR 2006-01-02}", []*TextPiece{{Text: "09h"}, {Text: " 2022-03-07", Align: RIGHT}}},
+	}
+
+	for i, test := range tests {
+		pieces := parseClock(NewTextParser(), test.layout)
+		output := clockText(pieces, now)
+		// We don't care about Origin
+		for _, piece := range output {
+			piece.Origin = nil
+		}
+		assertEqual(t, test.layout, test.output, output, "ClockText", i)
+
+		// Pieces get formatted anew every time.
+		later := clockText(pieces, now.Add(time.Hour))
+		assertEqual(t, test.layout, false, later[0].Text == output[0].Text, "ClockText", i)
+	}
+}
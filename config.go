@@ -0,0 +1,161 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configEntry is a flag set in a config file.
+type configEntry struct {
+	Name, Value string
+}
+
+// config stores flags read from a config file, in order they were given in.
+type config struct {
+	Entries []configEntry
+}
+
+// parseConfig reads config consisting of `<flag> = <value>` lines,
+// with flag names as on the command line, but without dashes.
+// Flag alone means true, values can be quoted, lines starting
+// with # are comments.
+func parseConfig(r io.Reader) (*config, error) {
+	c := &config{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		name, value, found := strings.Cut(text, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found {
+			value = "true"
+		}
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value %s", line, value)
+			}
+			value = unquoted
+		}
+		if name == "" {
+			return nil, fmt.Errorf("line %d: missing flag name", line)
+		}
+		c.Entries = append(c.Entries, configEntry{name, value})
+	}
+	return c, scanner.Err()
+}
+
+// apply sets flags of fs to values from config, unless they were
+// set explicitly already, so that command line overrides config.
+func (c *config) apply(fs *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, entry := range c.Entries {
+		if fs.Lookup(entry.Name) == nil {
+			return fmt.Errorf("unknown flag `%s`", entry.Name)
+		}
+		if explicit[entry.Name] {
+			continue
+		}
+		if err := fs.Set(entry.Name, entry.Value); err != nil {
+			return fmt.Errorf("invalid value `%s` for flag `%s`: %s", entry.Value, entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// themes are built-in themes, looked up before theme files.
+var themes = map[string]string{
+	"dark": `
+fg = 0xFFD8DEE9
+bg = 0xFF2E3440
+accent-line = 0xFF88C0D0:2
+urgent-color = 0xFFBF616A
+`,
+	"light": `
+fg = 0xFF2E3440
+bg = 0xFFECEFF4
+accent-line = 0xFF5E81AC:2
+urgent-color = 0xFFD08770
+`,
+}
+
+// themeFlags are the visual flags themes can set.
+var themeFlags = map[string]bool{
+	"fg": true, "bg": true, "fonts": true, "no-antialias": true,
+	"default-height": true, "valign": true, "border": true, "border-color": true,
+	"tab-width": true, "tracking": true, "hover-highlight": true, "text-outline": true,
+	"bg-image": true, "bg-image-mode": true, "dither": true,
+	"urgent-color": true, "urgent-period": true, "accent-line": true,
+	"mono": true, "fit-height": true, "edge-bleed": true, "gap-x": true, "gap-y": true,
+}
+
+// themePath returns path of the theme file of given name,
+// in $XDG_CONFIG_HOME/gobar/themes, or name itself, if it is a path.
+func themePath(name string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) {
+		return expandPath(name), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gobar", "themes", name+".conf"), nil
+}
+
+// loadTheme reads built-in theme or theme file of given name.
+// Theme is a config file setting visual flags, and only these.
+func loadTheme(name string) (*config, error) {
+	if theme, ok := themes[name]; ok {
+		return parseConfig(strings.NewReader(theme))
+	}
+	path, err := themePath(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load theme `%s`: %w", name, err)
+	}
+	defer file.Close()
+	theme, err := parseConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid theme `%s`: %w", path, err)
+	}
+	for _, entry := range theme.Entries {
+		if !themeFlags[entry.Name] {
+			return nil, fmt.Errorf("invalid theme `%s`: flag `%s` is not a visual one", path, entry.Name)
+		}
+	}
+	return theme, nil
+}
@@ -0,0 +1,144 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		input  string
+		output *config
+		err    error
+	}{
+		{"", &config{}, nil},
+		{"# comment\n\nfg = 0xFF000000\n  rows=2  \nwrap\n", &config{Entries: []configEntry{
+			{"fg", "0xFF000000"}, {"rows", "2"}, {"wrap", "true"},
+		}}, nil},
+		{`clock = "{F1 15:04} "`, &config{Entries: []configEntry{{"clock", "{F1 15:04} "}}}, nil},
+		{`fg = "0xFF`, nil, errors.New(`line 1: invalid quoted value "0xFF`)},
+		{"rows = 2\n= 2", nil, errors.New("line 2: missing flag name")},
+	}
+
+	for i, test := range tests {
+		actual, err := parseConfig(strings.NewReader(test.input))
+		assertEqual(t, test.input, test.output, actual, "ParseConfig", i)
+		assertEqualError(t, test.err, err, "ParseConfig", i)
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	tests := []struct {
+		args   []string
+		config *config
+		rows   int
+		wrap   bool
+		err    error
+	}{
+		{nil, &config{Entries: []configEntry{{"rows", "2"}, {"wrap", "true"}}}, 2, true, nil},
+		{[]string{"-rows", "3"}, &config{Entries: []configEntry{{"rows", "2"}}}, 3, false, nil},
+		{nil, &config{Entries: []configEntry{{"cols", "2"}}}, 1, false, errors.New("unknown flag `cols`")},
+		{nil, &config{Entries: []configEntry{{"rows", "x"}}}, 0, false, errors.New(
+			"invalid value `x` for flag `rows`: parse error",
+		)},
+	}
+
+	for i, test := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		rows := fs.Int("rows", 1, "")
+		wrap := fs.Bool("wrap", false, "")
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+
+		err := test.config.apply(fs)
+		assertEqual(t, test.args, test.rows, *rows, "ConfigApply", i)
+		assertEqual(t, test.args, test.wrap, *wrap, "ConfigApply", i)
+		assertEqualError(t, test.err, err, "ConfigApply", i)
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.MkdirAll(filepath.Join(dir, "gobar", "themes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "gobar", "themes", "mine.conf")
+	if err := os.WriteFile(path, []byte("bg = 0xFF112233\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "gobar", "themes", "socket.conf")
+	if err := os.WriteFile(socket, []byte("bg = 0xFF112233\nsocket = /tmp/gobar.sock\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		output *config
+		err    error
+	}{
+		{"mine", &config{Entries: []configEntry{{"bg", "0xFF112233"}}}, nil},
+		{path, &config{Entries: []configEntry{{"bg", "0xFF112233"}}}, nil},
+		{"missing", nil, errors.New("could not load theme `missing`: open " +
+			filepath.Join(dir, "gobar", "themes", "missing.conf") + ": no such file or directory")},
+		{"socket", nil, errors.New("invalid theme `" + socket + "`: flag `socket` is not a visual one")},
+	}
+
+	for i, test := range tests {
+		actual, err := loadTheme(test.name)
+		assertEqual(t, test.name, test.output, actual, "LoadTheme", i)
+		assertEqualError(t, test.err, err, "LoadTheme", i)
+	}
+
+	// Built-in themes must set only visual flags, with valid values.
+	for name := range themes {
+		theme, err := loadTheme(name)
+		if err != nil {
+			t.Fatalf("LoadTheme: built-in theme `%s`: %s", name, err)
+		}
+		for _, entry := range theme.Entries {
+			if !themeFlags[entry.Name] {
+				t.Errorf("LoadTheme: built-in theme `%s` sets flag `%s`, which is not a visual one", name, entry.Name)
+			}
+		}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fgColors, bgColors := colors{}, colors{}
+		var accentLine AccentLine
+		fs.Var(&fgColors, "fg", "")
+		fs.Var(&bgColors, "bg", "")
+		fs.Var(&accentLine, "accent-line", "")
+		fs.Uint64("urgent-color", 0, "")
+		if err := theme.apply(fs); err != nil {
+			t.Errorf("LoadTheme: built-in theme `%s`: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/ewmh"
+	"github.com/jezek/xgbutil/xgraphics"
+	"github.com/jezek/xgbutil/xwindow"
+)
+
+// drawer is what Bar needs from a display server to create its windows
+// and paint onto them. xDrawer talks to X, while tests use a fake one,
+// so that windows can be laid out and drawn without an X server.
+type drawer interface {
+	// rootHeight returns height of the root window.
+	rootHeight() int
+	// docks returns struts of existing windows reserving space,
+	// which windows already destroyed do not have any longer.
+	docks() []ewmh.WmStrutPartial
+	// createWindow creates a window covering rect, in root window
+	// coordinates, with attributes given by mask and values.
+	createWindow(rect image.Rectangle, mask int, values []uint32) (xproto.Window, error)
+	// windowRect returns area of an existing window,
+	// in root window coordinates.
+	windowRect(win xproto.Window) (image.Rectangle, error)
+	// setDockHints tells window manager that win is a dock,
+	// visible on all desktops, with given states.
+	setDockHints(win xproto.Window, states []string)
+	// setStruts reserves space for win.
+	setStruts(win xproto.Window, struts barStruts)
+	// listen selects events of win.
+	listen(win xproto.Window, events uint32)
+	// surface makes img painted onto win.
+	surface(img *xgraphics.Image, win xproto.Window)
+	// paint sends img to win it is a surface of.
+	paint(img *xgraphics.Image, win xproto.Window)
+	// paintRects sends only given areas of img to win.
+	paintRects(img *xgraphics.Image, win xproto.Window, rects []image.Rectangle)
+	mapWindow(win xproto.Window)
+	unmapWindow(win xproto.Window)
+	// destroyWindow destroys win if it is owned, otherwise only stops
+	// handling its events, e.g. for a window the bar is embedded into.
+	destroyWindow(win xproto.Window, owned bool)
+}
+
+// xDrawer is a drawer drawing on X server.
+type xDrawer struct {
+	X *xgbutil.XUtil
+}
+
+func (d *xDrawer) rootHeight() int {
+	return xwindow.RootGeometry(d.X).Height()
+}
+
+func (d *xDrawer) docks() []ewmh.WmStrutPartial {
+	return dockStruts(d.X)
+}
+
+func (d *xDrawer) createWindow(rect image.Rectangle, mask int, values []uint32) (xproto.Window, error) {
+	win, err := xwindow.Generate(d.X)
+	if err != nil {
+		return 0, err
+	}
+	win.Create(d.X.RootWin(), rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), mask, values...)
+	return win.Id, nil
+}
+
+func (d *xDrawer) windowRect(win xproto.Window) (image.Rectangle, error) {
+	geom, err := xwindow.New(d.X, win).Geometry()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	origin, err := xproto.TranslateCoordinates(
+		d.X.Conn(), win, d.X.RootWin(), 0, 0,
+	).Reply()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	x, y := int(origin.DstX), int(origin.DstY)
+	return image.Rect(x, y, x+geom.Width(), y+geom.Height()), nil
+}
+
+func (d *xDrawer) setDockHints(win xproto.Window, states []string) {
+	ewmh.WmWindowTypeSet(d.X, win, []string{"_NET_WM_WINDOW_TYPE_DOCK"})
+	ewmh.WmStateSet(d.X, win, states)
+	ewmh.WmDesktopSet(d.X, win, 0xFFFFFFFF)
+}
+
+func (d *xDrawer) setStruts(win xproto.Window, struts barStruts) {
+	ewmh.WmStrutPartialSet(d.X, win, struts.partial)
+	ewmh.WmStrutSet(d.X, win, struts.strut)
+}
+
+func (d *xDrawer) listen(win xproto.Window, events uint32) {
+	xwindow.New(d.X, win).Listen(int(events))
+}
+
+func (d *xDrawer) surface(img *xgraphics.Image, win xproto.Window) {
+	img.XSurfaceSet(win)
+}
+
+func (d *xDrawer) paint(img *xgraphics.Image, win xproto.Window) {
+	img.XDraw()
+	img.XPaint(win)
+}
+
+func (d *xDrawer) paintRects(img *xgraphics.Image, win xproto.Window, rects []image.Rectangle) {
+	img.XPaintRects(win, rects...)
+}
+
+func (d *xDrawer) mapWindow(win xproto.Window) {
+	xwindow.New(d.X, win).Map()
+}
+
+func (d *xDrawer) unmapWindow(win xproto.Window) {
+	xwindow.New(d.X, win).Unmap()
+}
+
+func (d *xDrawer) destroyWindow(win xproto.Window, owned bool) {
+	if owned {
+		xwindow.New(d.X, win).Destroy()
+	} else {
+		xwindow.New(d.X, win).Detach()
+	}
+}
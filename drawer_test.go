@@ -0,0 +1,322 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+	"sync"
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/ewmh"
+	"github.com/jezek/xgbutil/xevent"
+	"github.com/jezek/xgbutil/xgraphics"
+	"github.com/jezek/xgbutil/xinerama"
+	"github.com/jezek/xgbutil/xrect"
+	"golang.org/x/image/font/inconsolata"
+)
+
+// fakeWindow is a window of fakeDrawer.
+type fakeWindow struct {
+	rect   image.Rectangle
+	owned  bool
+	mapped bool
+	// destroyed is set for destroyed owned windows, and for not owned
+	// windows which events are not handled anymore.
+	destroyed bool
+	states    []string
+	struts    barStruts
+	events    uint32
+	surface   *xgraphics.Image
+	// paints counts images painted onto the window.
+	paints int
+	// rects are areas painted onto the window alone.
+	rects []image.Rectangle
+}
+
+// fakeDrawer keeps windows in memory, instead of creating them on X server.
+type fakeDrawer struct {
+	root image.Rectangle
+	// others are struts of docks other than the bar.
+	others  []ewmh.WmStrutPartial
+	windows map[xproto.Window]*fakeWindow
+	next    xproto.Window
+}
+
+// newFakeDrawer creates fakeDrawer with root window of given size.
+func newFakeDrawer(width, height int) *fakeDrawer {
+	return &fakeDrawer{
+		root:    image.Rect(0, 0, width, height),
+		windows: map[xproto.Window]*fakeWindow{},
+		next:    1,
+	}
+}
+
+// existing adds a window, not owned by the bar, e.g. to embed it into.
+func (d *fakeDrawer) existing(rect image.Rectangle) xproto.Window {
+	win := d.next
+	d.next++
+	d.windows[win] = &fakeWindow{rect: rect}
+	return win
+}
+
+func (d *fakeDrawer) rootHeight() int {
+	return d.root.Dy()
+}
+
+func (d *fakeDrawer) docks() []ewmh.WmStrutPartial {
+	docks := append([]ewmh.WmStrutPartial{}, d.others...)
+	for _, window := range d.windows {
+		if !window.destroyed && window.struts.partial != nil {
+			docks = append(docks, *window.struts.partial)
+		}
+	}
+	return docks
+}
+
+func (d *fakeDrawer) createWindow(rect image.Rectangle, mask int, values []uint32) (xproto.Window, error) {
+	win := d.next
+	d.next++
+	d.windows[win] = &fakeWindow{rect: rect, owned: true}
+	return win, nil
+}
+
+func (d *fakeDrawer) windowRect(win xproto.Window) (image.Rectangle, error) {
+	return d.windows[win].rect, nil
+}
+
+func (d *fakeDrawer) setDockHints(win xproto.Window, states []string) {
+	d.windows[win].states = states
+}
+
+func (d *fakeDrawer) setStruts(win xproto.Window, struts barStruts) {
+	d.windows[win].struts = struts
+}
+
+func (d *fakeDrawer) listen(win xproto.Window, events uint32) {
+	d.windows[win].events = events
+}
+
+func (d *fakeDrawer) surface(img *xgraphics.Image, win xproto.Window) {
+	d.windows[win].surface = img
+}
+
+func (d *fakeDrawer) paint(img *xgraphics.Image, win xproto.Window) {
+	if d.windows[win].surface == img {
+		d.windows[win].paints++
+	}
+}
+
+func (d *fakeDrawer) paintRects(img *xgraphics.Image, win xproto.Window, rects []image.Rectangle) {
+	if d.windows[win].surface == img {
+		d.windows[win].rects = append(d.windows[win].rects, rects...)
+	}
+}
+
+func (d *fakeDrawer) mapWindow(win xproto.Window) {
+	d.windows[win].mapped = true
+}
+
+func (d *fakeDrawer) unmapWindow(win xproto.Window) {
+	d.windows[win].mapped = false
+}
+
+func (d *fakeDrawer) destroyWindow(win xproto.Window, owned bool) {
+	d.windows[win].destroyed = true
+	if owned {
+		d.windows[win].mapped = false
+	}
+}
+
+// createFake creates windows of bar with fake drawer, one on every
+// head of given width, all next to each other and 1080 pixels high.
+func createFake(bar *Bar, geometries []*Geometry, widths ...int) *fakeDrawer {
+	d := newFakeDrawer(0, 1080)
+	for _, width := range widths {
+		bar.heads = append(bar.heads, xrect.New(d.root.Dx(), 0, width, 1080))
+		d.root.Max.X += width
+	}
+	// Callbacks are there for windows listening to events.
+	bar.X = &xgbutil.XUtil{
+		Callbacks:    map[int]map[xproto.Window][]xgbutil.Callback{},
+		CallbacksLck: &sync.RWMutex{},
+	}
+	bar.drawer = d
+	bar.create(geometries, TOP)
+	return d
+}
+
+func TestBarCreate(t *testing.T) {
+	tests := []struct {
+		geometries []*Geometry
+		position   Position
+		options    Options
+		rects      []image.Rectangle
+		struts     []*ewmh.WmStrut
+		states     []string
+	}{
+		{nil, TOP, Options{}, []image.Rectangle{image.Rect(0, 0, 1920, 16), image.Rect(1920, 0, 3200, 16)},
+			[]*ewmh.WmStrut{{Top: 16}, {Top: 16}}, []string{"_NET_WM_STATE_STICKY"}},
+		{[]*Geometry{{Width: 100, Height: 20}, nil}, BOTTOM, Options{}, []image.Rectangle{image.Rect(0, 1060, 100, 1080)},
+			[]*ewmh.WmStrut{{Bottom: 20}}, []string{"_NET_WM_STATE_STICKY"}},
+		{[]*Geometry{{Height: 20}}, TOP, Options{HideOnFullscreen: true}, []image.Rectangle{image.Rect(0, 0, 1920, 20), image.Rect(1920, 0, 3200, 20)},
+			[]*ewmh.WmStrut{{Top: 20}, {Top: 20}}, []string{"_NET_WM_STATE_STICKY", "_NET_WM_STATE_BELOW"}},
+		{nil, TOP, Options{NoEWMH: true}, []image.Rectangle{image.Rect(0, 0, 1920, 16), image.Rect(1920, 0, 3200, 16)},
+			[]*ewmh.WmStrut{nil, nil}, nil},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{Fonts: fonts{inconsolata.Regular8x16}, Options: test.options}
+		d := newFakeDrawer(3200, 1080)
+		bar.X = &xgbutil.XUtil{}
+		bar.drawer = d
+		bar.heads = xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)}
+		bar.create(test.geometries, test.position)
+
+		var rects []image.Rectangle
+		var struts []*ewmh.WmStrut
+		for j, window := range bar.Windows {
+			win := d.windows[window.Id]
+			rects = append(rects, win.rect)
+			struts = append(struts, win.struts.strut)
+			assertEqual(t, test.geometries, test.states, win.states, "BarCreate", i)
+			assertEqual(t, test.geometries, bar.canvases[j].img, win.surface, "BarCreate", i)
+			assertEqual(t, test.geometries, false, win.mapped, "BarCreate", i)
+		}
+		assertEqual(t, test.geometries, test.rects, rects, "BarCreate", i)
+		assertEqual(t, test.geometries, test.struts, struts, "BarCreate", i)
+	}
+}
+
+func TestBarCreate_embed(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	d := newFakeDrawer(1920, 1080)
+	win := d.existing(image.Rect(10, 20, 110, 40))
+	bar := &Bar{
+		X: &xgbutil.XUtil{
+			Callbacks:    map[int]map[xproto.Window][]xgbutil.Callback{},
+			CallbacksLck: &sync.RWMutex{},
+		},
+		drawer:     d,
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+		Options:    Options{Window: win, ClickCopy: true},
+	}
+	bar.create(nil, TOP)
+
+	assertEqual(t, win, []*Geometry{{Width: 100, Height: 20}}, bar.Geometries, "BarCreate_embed", 0)
+	assertEqual(t, win, []image.Rectangle{image.Rect(10, 20, 110, 40)}, bar.rects, "BarCreate_embed", 0)
+	assertEqual(t, win, 1, len(d.windows), "BarCreate_embed", 0)
+	// Clicks are left to the owner of the window.
+	assertEqual(t, win, uint32(xproto.EventMaskStructureNotify), d.windows[win].events, "BarCreate_embed", 0)
+	assertEqual(t, win, 0, len(bar.X.Callbacks[xevent.ButtonPress][win]), "BarCreate_embed", 0)
+
+	// Window stays mapped by its owner only, whatever hides the bar.
+	bar.toggle()
+	bar.Draw(nil)
+	assertEqual(t, win, false, d.windows[win].mapped, "BarCreate_embed", 1)
+
+	// Bar follows resizes of the window.
+	d.windows[win].rect = image.Rect(10, 20, 210, 44)
+	for _, callback := range bar.X.Callbacks[xevent.ConfigureNotify][win] {
+		callback.Run(bar.X, xevent.ConfigureNotifyEvent{ConfigureNotifyEvent: &xproto.ConfigureNotifyEvent{Width: 200, Height: 24}})
+	}
+	assertEqual(t, win, []*Geometry{{Width: 200, Height: 24}}, bar.Geometries, "BarCreate_embed", 2)
+
+	bar.destroy()
+	assertEqual(t, win, true, d.windows[win].destroyed, "BarCreate_embed", 3)
+	assertEqual(t, win, false, d.windows[win].owned, "BarCreate_embed", 3)
+}
+
+func TestBarResize_respectStruts(t *testing.T) {
+	bar := &Bar{Fonts: fonts{inconsolata.Regular8x16}, Options: Options{RespectStruts: true}, position: TOP}
+	d := createFake(bar, nil, 1920, 1280)
+	d.others = []ewmh.WmStrutPartial{{Top: 20, TopStartX: 1920, TopEndX: 3199}}
+
+	bar.resize(24)
+
+	// Space reserved by the bar itself before is not there anymore.
+	expected := []image.Rectangle{image.Rect(0, 0, 1920, 24), image.Rect(1920, 20, 3200, 44)}
+	assertEqual(t, d.others, expected, bar.rects, "BarResize_respectStruts", 0)
+}
+
+func TestBarDraw_fake(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	bar := &Bar{
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+	}
+	d := createFake(bar, nil, 32, 64)
+
+	bar.Draw([]*TextPiece{{Text: "test"}})
+	bar.toggle()
+	bar.Draw([]*TextPiece{{Text: "test"}})
+
+	var windows []*fakeWindow
+	for _, window := range bar.Windows {
+		windows = append(windows, d.windows[window.Id])
+	}
+	for _, win := range windows {
+		assertEqual(t, win.rect, 2, win.paints, "BarDraw_fake", 0)
+		// Hidden windows are painted, but not mapped, with their struts released.
+		assertEqual(t, win.rect, false, win.mapped, "BarDraw_fake", 0)
+		assertEqual(t, win.rect, &ewmh.WmStrut{}, win.struts.strut, "BarDraw_fake", 0)
+	}
+
+	bar.toggle()
+	for _, win := range windows {
+		assertEqual(t, win.rect, true, win.mapped, "BarDraw_fake", 1)
+		assertEqual(t, win.rect, &ewmh.WmStrut{Top: 16}, win.struts.strut, "BarDraw_fake", 1)
+	}
+
+	bar.destroy()
+	for _, win := range windows {
+		assertEqual(t, win.rect, true, win.destroyed, "BarDraw_fake", 2)
+		assertEqual(t, win.rect, false, win.mapped, "BarDraw_fake", 2)
+	}
+}
+
+func TestBarHover_fake(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	bar := &Bar{
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+		Options:    Options{HoverHighlight: &xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0x80}},
+	}
+	d := createFake(bar, nil, 32)
+	bar.Draw([]*TextPiece{{Text: "test"}})
+
+	id := bar.Windows[0].Id
+	win := d.windows[id]
+	assertEqual(t, id, uint32(xproto.EventMaskPointerMotion|xproto.EventMaskLeaveWindow), win.events, "BarHover_fake", 0)
+
+	motion := xevent.MotionNotifyEvent{MotionNotifyEvent: &xproto.MotionNotifyEvent{EventX: 4, EventY: 8}}
+	for _, callback := range bar.X.Callbacks[xevent.MotionNotify][id] {
+		callback.Run(bar.X, motion)
+	}
+	assertEqual(t, id, []image.Rectangle{image.Rect(0, 0, 32, 16)}, win.rects, "BarHover_fake", 1)
+}
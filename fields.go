@@ -0,0 +1,77 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// FieldParser is used to create a set of TextPieces from plain text
+// fields separated by Sep, for producers that cannot emit markup.
+// Fields take turns being left and right aligned, with right aligned
+// ones kept in order they were written in. Their text is taken as it is,
+// with bar default colors and font.
+type FieldParser struct {
+	Sep string
+	// MultiLine makes newlines start new text rows,
+	// instead of ending the scan.
+	MultiLine bool
+}
+
+// Scan scans fields and returns array of TextPieces.
+// Empty fields are omitted in the returned array.
+func (fp *FieldParser) Scan(r io.Reader) []*TextPiece {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logEvery(hotLogInterval, WARN, "Problem reading fields: %s", err)
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if !fp.MultiLine {
+		text, _, _ = strings.Cut(text, "\n")
+	}
+
+	var pieces []*TextPiece
+	for row, line := range strings.Split(text, "\n") {
+		var left, right []*TextPiece
+		for i, field := range strings.Split(line, fp.Sep) {
+			if field == "" {
+				continue
+			}
+			if i%2 == 0 {
+				left = append(left, &TextPiece{Text: field, Row: uint(row)})
+			} else {
+				// Right aligned pieces are drawn from right to left.
+				right = append([]*TextPiece{{Text: field, Align: RIGHT, Row: uint(row)}}, right...)
+			}
+		}
+		pieces = append(append(pieces, left...), right...)
+	}
+	return pieces
+}
+
+// ScanWithErrors works like Scan. Fields are taken as they are,
+// so there are never any problems found in them.
+func (fp *FieldParser) ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError) {
+	return fp.Scan(r), nil
+}
@@ -0,0 +1,55 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldParser(t *testing.T) {
+	tests := []struct {
+		input     string
+		multiLine bool
+		output    []*TextPiece
+	}{
+		{"test\n", false, []*TextPiece{{Text: "test"}}},
+		{"test1|test2\n", false, []*TextPiece{{Text: "test1"}, {Text: "test2", Align: RIGHT}}},
+		{"test1|test2|test3|test4", false, []*TextPiece{
+			{Text: "test1"}, {Text: "test3"}, {Text: "test4", Align: RIGHT}, {Text: "test2", Align: RIGHT},
+		}},
+		{"|test2||test4", false, []*TextPiece{{Text: "test4", Align: RIGHT}, {Text: "test2", Align: RIGHT}}},
+		{"{F1test1}|<b>test2</b>", false, []*TextPiece{{Text: "{F1test1}"}, {Text: "<b>test2</b>", Align: RIGHT}}},
+		{"test1|test2\ntest3", false, []*TextPiece{{Text: "test1"}, {Text: "test2", Align: RIGHT}}},
+		{"test1|test2\ntest3\n", true, []*TextPiece{
+			{Text: "test1"}, {Text: "test2", Align: RIGHT}, {Text: "test3", Row: 1},
+		}},
+		{"", false, nil},
+	}
+
+	for i, test := range tests {
+		parser := &FieldParser{Sep: "|", MultiLine: test.multiLine}
+		output := parser.Scan(strings.NewReader(test.input))
+		assertEqual(t, test.input, test.output, output, "FieldParser", i)
+	}
+}
@@ -23,11 +23,14 @@
 package main
 
 import (
+	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/adrg/sysfont"
 	"github.com/flopp/go-findfont"
@@ -37,77 +40,375 @@ import (
 	"golang.org/x/image/font/opentype"
 )
 
-func findFont(def string) font.Face {
+// fontInfo describes font file a font definition got resolved to.
+type fontInfo struct {
+	Path   string
+	Family string
+	Size   float64
+	// Baseline is an offset text in this font is drawn at, in pixels.
+	Baseline int
+}
+
+// bundledFontInfo describes the bundled font used when nothing else is found.
+var bundledFontInfo = fontInfo{Path: "bundled", Family: "Inconsolata", Size: 16}
+
+// findFont returns face for font definition in form of
+// name[:size[:style...]], where style is bold, italic,
+// or one of the face options, see parseFontOptions.
+// If such font cannot be found, the closest available font is returned
+// instead, together with an error describing that.
+func findFont(def string) (font.Face, error) {
+	face, _, err := resolveFont(def)
+	return face, err
+}
+
+// resolveFont works like findFont, but also describes the font it found.
+func resolveFont(def string) (font.Face, fontInfo, error) {
+	def, opts := parseFontOptions(def)
+	face, info, err := resolveFontFace(def, opts)
+	info.Baseline = opts.Baseline
+	return face, info, err
+}
+
+// resolveFontFace works like resolveFont, for definition with
+// face options already taken out of it.
+func resolveFontFace(def string, opts fontOptions) (font.Face, fontInfo, error) {
+	def = parseFontStyles(def)
 	i := strings.LastIndexByte(def, ':')
 	name, size := parseSize(def, i)
+	name = expandPath(name)
 
 	fontPath, err := findfont.Find(name)
 	if err != nil {
-		log.Printf("Could not find font `%s`, trying alternate method: %s", def, err)
-		return findFontFallback(def, size)
+		logf(WARN, "Could not find font `%s`, trying alternate method: %s", def, err)
+		return resolveFontFallback(def, size, opts)
 	}
 	fontFile, err := os.Open(fontPath)
 	if err != nil {
-		log.Printf("Could not open font `%s`, trying to find another one: %s", fontPath, err)
-		return findFontFallback(def, size)
+		logf(WARN, "Could not open font `%s`, trying to find another one: %s", fontPath, err)
+		return resolveFontFallback(def, size, opts)
 	}
-	face, err := parseFontFace(fontFile, size)
+	face, err := parseFontFace(fontFile, size, opts)
 	if err != nil {
-		log.Printf("Could not parse font `%s`, trying to find another one: %s", fontPath, err)
-		return findFontFallback(def, size)
+		logf(WARN, "Could not parse font `%s`, trying to find another one: %s", fontPath, err)
+		return resolveFontFallback(def, size, opts)
 	}
-	return face
+	return face, fontInfo{Path: fontPath, Size: size}, nil
 }
 
 var fallbackFinder *sysfont.Finder = nil
 
-func findFontFallback(def string, size float64) font.Face {
+// findFontFallback returns face for the system font best matching def.
+// Returns an error if matched font does not seem to be the one asked for,
+// or if nothing could be found and bundled inconsolata is used.
+func findFontFallback(def string, size float64) (font.Face, error) {
+	face, _, err := resolveFontFallback(def, size, fontOptions{})
+	return face, err
+}
+
+// resolveFontFallback works like findFontFallback,
+// but also describes the font it found and takes face options.
+func resolveFontFallback(def string, size float64, opts fontOptions) (font.Face, fontInfo, error) {
 	if fallbackFinder == nil {
 		fallbackFinder = sysfont.NewFinder(nil)
 	}
 
 	fontDef := fallbackFinder.Match(def)
 	if fontDef == nil {
-		log.Printf("Could not find font `%s`, using `inconsolata regular 8x16`", def)
-		return inconsolata.Regular8x16
+		logf(WARN, "Could not find font `%s`, using `inconsolata regular 8x16`", def)
+		return inconsolata.Regular8x16, bundledFontInfo, fmt.Errorf("font `%s` not found", def)
 	}
 	fontFile, err := os.Open(fontDef.Filename)
 	if err != nil {
-		log.Printf("Could not open font `%s`, using `inconsolata regular 8x16`: %s", fontDef.Filename, err)
-		return inconsolata.Regular8x16
+		logf(WARN, "Could not open font `%s`, using `inconsolata regular 8x16`: %s", fontDef.Filename, err)
+		return inconsolata.Regular8x16, bundledFontInfo, fmt.Errorf("font `%s` not found: %s", def, err)
 	}
-	face, err := parseFontFace(fontFile, size)
+	face, err := parseFontFace(fontFile, size, opts)
 	if err != nil {
-		log.Printf("Could not parse font `%s`, using `inconsolata regular 8x16`: %s", fontDef.Filename, err)
-		return inconsolata.Regular8x16
+		logf(WARN, "Could not parse font `%s`, using `inconsolata regular 8x16`: %s", fontDef.Filename, err)
+		return inconsolata.Regular8x16, bundledFontInfo, fmt.Errorf("font `%s` not found: %s", def, err)
+	}
+	logf(INFO, "Found fallback font `%s`", fontDef.Filename)
+	info := fontInfo{Path: fontDef.Filename, Family: fontDef.Family, Size: size}
+	if !fontMatches(def, fontDef) {
+		return face, info, fmt.Errorf("font `%s` not found, closest match is `%s`", def, fontDef.Filename)
+	}
+	return face, info, nil
+}
+
+// fontFamily returns family of the system font at path, as known to sysfont.
+// Returns empty string if there is no such font.
+func fontFamily(path string) string {
+	if fallbackFinder == nil {
+		fallbackFinder = sysfont.NewFinder(nil)
+	}
+	for _, fontDef := range fallbackFinder.List() {
+		if filepath.Clean(fontDef.Filename) == filepath.Clean(path) {
+			return fontDef.Family
+		}
+	}
+	return ""
+}
+
+// listFonts writes <index>\t<path>\t<family>\t<size> line to w
+// for every one of infos.
+func listFonts(w io.Writer, infos []fontInfo) error {
+	for i, info := range infos {
+		family := info.Family
+		if family == "" {
+			family = fontFamily(info.Path)
+		}
+		if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%g\n", i, info.Path, family, info.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fontMatches checks whether font found by the fallback method
+// is the one defined by def, rather than just a substitute for it.
+func fontMatches(def string, fontDef *sysfont.Font) bool {
+	normalize := strings.NewReplacer(" ", "", "-", "", "_", "").Replace
+
+	name := def
+	if i := strings.LastIndexByte(name, ':'); i != -1 {
+		name = name[:i]
+	}
+	if strings.ContainsRune(name, os.PathSeparator) {
+		name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	query := normalize(strings.ToLower(name))
+	if query == "" {
+		return true
+	}
+
+	family := normalize(strings.ToLower(fontDef.Family))
+	file := filepath.Base(fontDef.Filename)
+	file = normalize(strings.ToLower(strings.TrimSuffix(file, filepath.Ext(file))))
+	return strings.Contains(normalize(strings.ToLower(fontDef.Name)), query) ||
+		strings.Contains(file, query) ||
+		(family != "" && strings.Contains(query, family))
+}
+
+// parseFontStyles turns font definition in form of name:size:style...
+// into name[:size] form, with styles added to the name,
+// so that font finders can match the styled face.
+func parseFontStyles(def string) string {
+	parts := strings.Split(def, ":")
+	if len(parts) <= 2 {
+		return def
+	}
+	var bold, italic bool
+	for _, style := range parts[2:] {
+		switch strings.ToLower(style) {
+		case "bold":
+			bold = true
+		case "italic", "oblique":
+			italic = true
+		default:
+			logf(WARN, "Unknown font style `%s` for `%s`, ignoring", style, def)
+		}
+	}
+	name := parts[0]
+	if parts[1] != "" {
+		name += ":" + parts[1]
+	}
+	return styledFontDef(name, bold, italic)
+}
+
+// fontOptions override how a font face is rendered.
+type fontOptions struct {
+	// DPI is used instead of the default 72, if set.
+	DPI float64
+	// Hinting is used instead of the default one, if set.
+	Hinting *font.Hinting
+	// Baseline moves text drawn in the font down by that many pixels,
+	// or up, if negative.
+	Baseline int
+}
+
+// fontHintings are names of hinting values that can be set in fontOptions.
+var fontHintings = map[string]font.Hinting{
+	"none":     font.HintingNone,
+	"vertical": font.HintingVertical,
+	"full":     font.HintingFull,
+}
+
+// parseFontOptions takes face options in form of dpi=<dpi>,
+// hinting=<none|vertical|full> and baseline=<offset> out of font
+// definition in form of name[:size]:option..., returning
+// the remaining definition.
+func parseFontOptions(def string) (string, fontOptions) {
+	var opts fontOptions
+	parts := strings.Split(def, ":")
+	if len(parts) <= 1 {
+		return def, opts
 	}
-	log.Printf("Found fallback font `%s`", fontDef.Filename)
-	return face
+	rest := append([]string{}, parts[:1]...)
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, "=")
+		switch {
+		case !ok:
+			rest = append(rest, part)
+		case key == "dpi":
+			dpi, err := strconv.ParseFloat(value, 64)
+			if err != nil || dpi <= 0 {
+				logf(WARN, "Invalid font DPI `%s` for `%s`, ignoring", value, def)
+				continue
+			}
+			opts.DPI = dpi
+		case key == "hinting":
+			hinting, ok := fontHintings[value]
+			if !ok {
+				logf(WARN, "Unknown font hinting `%s` for `%s`, ignoring", value, def)
+				continue
+			}
+			opts.Hinting = &hinting
+		case key == "baseline":
+			baseline, err := strconv.Atoi(value)
+			if err != nil {
+				logf(WARN, "Invalid font baseline `%s` for `%s`, ignoring", value, def)
+				continue
+			}
+			opts.Baseline = baseline
+		default:
+			logf(WARN, "Unknown font option `%s` for `%s`, ignoring", key, def)
+		}
+	}
+	return strings.Join(rest, ":"), opts
 }
 
-func parseFontFace(file io.Reader, size float64) (font.Face, error) {
+// noAntialias makes parseFontFace hint glyph outlines to whole pixels,
+// so that they stay crisp when drawn without antialiasing.
+// It is set by flags, all parsed before any fonts are resolved.
+var noAntialias bool
+
+func parseFontFace(file io.Reader, size float64, opts fontOptions) (font.Face, error) {
 	otf, err := xgraphics.ParseFont(file)
 	if err != nil {
 		return nil, err
 	}
+	hinting := font.HintingNone
+	if noAntialias {
+		hinting = font.HintingFull
+	}
+	if opts.Hinting != nil {
+		hinting = *opts.Hinting
+	}
 	// XXX Can we somehow figure out DPI?
-	face, err := opentype.NewFace(otf, &opentype.FaceOptions{Size: size, DPI: 72})
+	dpi := 72.0
+	if opts.DPI != 0 {
+		dpi = opts.DPI
+	}
+	face, err := opentype.NewFace(otf, &opentype.FaceOptions{Size: size, DPI: dpi, Hinting: hinting})
 	if err != nil {
 		return nil, err
 	}
 	return face, nil
 }
 
+// faceHeight returns height of lines of text drawn with face,
+// in whole pixels. Bitmap fonts made for lines of exactly that height
+// can have ascent and descent adding to more.
+func faceHeight(face font.Face) int {
+	return face.Metrics().Height.Ceil()
+}
+
+// fitFont returns face for font definition, scaled down to be
+// at most height pixels high, if face found for it is higher.
+// Faces are scaled by lowering their DPI, keeping the other options.
+func fitFont(def string, face font.Face, height int) font.Face {
+	current := faceHeight(face)
+	if height <= 0 || current <= height {
+		return face
+	}
+	def, opts := parseFontOptions(def)
+	if opts.DPI == 0 {
+		opts.DPI = 72
+	}
+	opts.DPI *= float64(height) / float64(current)
+	fitted, _, err := resolveFontFace(def, opts)
+	if err != nil {
+		return face
+	}
+	logf(INFO, "Scaled font `%s` down from %dpx to %dpx", def, current, faceHeight(fitted))
+	return fitted
+}
+
 func parseSize(def string, i int) (string, float64) {
 	if i == -1 {
-		log.Printf("Font size not specified for `%s`, using `12`", def)
+		logf(WARN, "Font size not specified for `%s`, using `12`", def)
 		return def, 12
 	}
 	name, sizeStr := def[:i], def[i+1:]
 	size, err := strconv.ParseFloat(sizeStr, 32)
 	if err != nil {
-		log.Printf("Invalid font size `%s` for `%s`, using `12`: `%s`", sizeStr, name, err)
+		logf(WARN, "Invalid font size `%s` for `%s`, using `12`: `%s`", sizeStr, name, err)
 		size = 12
 	}
 	return name, size
 }
+
+// fontsWatchInterval is the time between checks for font changes.
+const fontsWatchInterval = 5 * time.Second
+
+// fontDirs returns directories fonts are usually installed to,
+// together with fontconfig cache directories, which change
+// whenever fontconfig cache gets rebuilt.
+func fontDirs() []string {
+	dirs := []string{
+		"/usr/share/fonts",
+		"/usr/local/share/fonts",
+		"/var/cache/fontconfig",
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(home, ".fonts"),
+			filepath.Join(home, ".local", "share", "fonts"),
+		)
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "fonts"))
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		dirs = append(dirs, filepath.Join(cacheDir, "fontconfig"))
+	}
+	return dirs
+}
+
+// fontsStamp returns the latest modification time of dirs
+// and all directories within them. Nonexistent dirs are skipped.
+func fontsStamp(dirs []string) time.Time {
+	var stamp time.Time
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.ModTime().After(stamp) {
+				stamp = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return stamp
+}
+
+// watchFonts checks dirs every interval and notifies on changes
+// whenever their fontsStamp changes.
+func watchFonts(dirs []string, interval time.Duration, changes chan<- struct{}) {
+	stamp := fontsStamp(dirs)
+	for range time.Tick(interval) {
+		newStamp := fontsStamp(dirs)
+		if newStamp.Equal(stamp) {
+			continue
+		}
+		stamp = newStamp
+		changes <- struct{}{}
+	}
+}
+
+// resetFontFinder makes next fallback font lookup rescan the system fonts.
+func resetFontFinder() {
+	fallbackFinder = nil
+}
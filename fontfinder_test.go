@@ -0,0 +1,221 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrg/sysfont"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestFontsStamp(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(sub, older, newer)
+	os.Chtimes(root, older, older)
+
+	tests := []struct {
+		input  []string
+		output time.Time
+	}{
+		{nil, time.Time{}},
+		{[]string{filepath.Join(root, "nonexistent")}, time.Time{}},
+		{[]string{root}, newer},
+		{[]string{sub, filepath.Join(root, "nonexistent")}, newer},
+	}
+
+	for i, test := range tests {
+		actual := fontsStamp(test.input)
+
+		assertEqual(t, test.input, test.output.Unix(), actual.Unix(), "FontsStamp", i)
+	}
+}
+
+func TestFontMatches(t *testing.T) {
+	dejavu := &sysfont.Font{
+		Family:   "DejaVu Sans",
+		Name:     "DejaVu Sans Bold",
+		Filename: "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf",
+	}
+	tests := []struct {
+		input  string
+		output bool
+	}{
+		{"", true},
+		{"DejaVu Sans", true},
+		{"dejavu sans bold:10", true},
+		{"DejaVuSans-Bold", true},
+		{"DejaVu Sans Oblique", true},
+		{"/usr/share/fonts/DejaVuSans-Bold.ttf", true},
+		{"Liberation Mono", false},
+		{"/nonexistent/font.ttf:12", false},
+	}
+
+	for i, test := range tests {
+		actual := fontMatches(test.input, dejavu)
+
+		assertEqual(t, test.input, test.output, actual, "FontMatches", i)
+	}
+}
+
+func TestListFonts(t *testing.T) {
+	tests := []struct {
+		input  []fontInfo
+		output string
+	}{
+		{nil, ""},
+		{[]fontInfo{bundledFontInfo}, "0\tbundled\tInconsolata\t16\n"},
+		{
+			[]fontInfo{
+				{Path: "/usr/share/fonts/DejaVuSans.ttf", Family: "DejaVu Sans", Size: 10.5},
+				{Path: "/usr/share/fonts/LiberationMono.ttf", Family: "Liberation Mono", Size: 12},
+			},
+			"0\t/usr/share/fonts/DejaVuSans.ttf\tDejaVu Sans\t10.5\n" +
+				"1\t/usr/share/fonts/LiberationMono.ttf\tLiberation Mono\t12\n",
+		},
+	}
+
+	for i, test := range tests {
+		var out strings.Builder
+		err := listFonts(&out, test.input)
+
+		assertEqual(t, test.input, nil, err, "ListFonts", i)
+		assertEqual(t, test.input, test.output, out.String(), "ListFonts", i)
+	}
+}
+
+func TestParseFontStyles(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"", ""},
+		{"DejaVu Sans", "DejaVu Sans"},
+		{"DejaVu Sans:10", "DejaVu Sans:10"},
+		{"DejaVu Sans:10:bold", "DejaVu Sans Bold:10"},
+		{"DejaVu Sans:10:italic", "DejaVu Sans Italic:10"},
+		{"DejaVu Sans:10:Bold:Italic", "DejaVu Sans Bold Italic:10"},
+		{"DejaVu Sans:10:oblique", "DejaVu Sans Italic:10"},
+		{"DejaVu Sans Bold:10:bold", "DejaVu Sans Bold:10"},
+		{"DejaVu Sans::bold", "DejaVu Sans Bold"},
+		{"DejaVu Sans:10:wide", "DejaVu Sans:10"},
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	for i, test := range tests {
+		actual := parseFontStyles(test.input)
+
+		assertEqual(t, test.input, test.output, actual, "ParseFontStyles", i)
+	}
+}
+
+func TestParseFontOptions(t *testing.T) {
+	full, none := font.HintingFull, font.HintingNone
+	tests := []struct {
+		input  string
+		output string
+		opts   fontOptions
+	}{
+		{"DejaVu Sans", "DejaVu Sans", fontOptions{}},
+		{"DejaVu Sans:10:bold", "DejaVu Sans:10:bold", fontOptions{}},
+		{"DejaVu Sans:10:dpi=96", "DejaVu Sans:10", fontOptions{DPI: 96}},
+		{"DejaVu Sans:dpi=96", "DejaVu Sans", fontOptions{DPI: 96}},
+		{"DejaVu Sans:10:bold:hinting=full:italic", "DejaVu Sans:10:bold:italic", fontOptions{Hinting: &full}},
+		{"icons:12:hinting=none:dpi=110.5", "icons:12", fontOptions{DPI: 110.5, Hinting: &none}},
+		{"icons:12:baseline=-2", "icons:12", fontOptions{Baseline: -2}},
+		{"DejaVu Sans:10:dpi=-1:hinting=some:width=2:baseline=x", "DejaVu Sans:10", fontOptions{}},
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	for i, test := range tests {
+		actual, opts := parseFontOptions(test.input)
+
+		assertEqual(t, test.input, test.output, actual, "ParseFontOptions", i)
+		assertEqual(t, test.input, test.opts, opts, "ParseFontOptions", i)
+	}
+}
+
+func TestParseFontFace(t *testing.T) {
+	tests := []struct {
+		input  fontOptions
+		output int
+	}{
+		{fontOptions{}, 14},
+		{fontOptions{DPI: 144}, 28},
+	}
+
+	for i, test := range tests {
+		face, err := parseFontFace(bytes.NewReader(goregular.TTF), 12, test.input)
+		assertEqualError(t, nil, err, "ParseFontFace", i)
+		actual := face.Metrics().Ascent + face.Metrics().Descent
+		assertEqual(t, test.input, test.output, actual.Ceil(), "ParseFontFace", i)
+	}
+}
+
+func TestFitFont(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goregular.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		def    string
+		height int
+		output int
+	}{
+		{path + ":12", 16, 14},
+		{path + ":24", 16, 16},
+		{path + ":12:dpi=144", 16, 16},
+		{path + ":24", 0, 28},
+	}
+
+	for i, test := range tests {
+		face, err := findFont(test.def)
+		assertEqualError(t, nil, err, "FitFont", i)
+		fitted := fitFont(test.def, face, test.height)
+		assertEqual(t, test.def, test.output, faceHeight(fitted), "FitFont", i)
+	}
+}
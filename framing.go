@@ -0,0 +1,74 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxFrameSize is the largest length-prefixed frame accepted,
+// anything bigger most likely means producer is out of sync.
+const maxFrameSize = 1 << 20
+
+// readFrame reads a single `<n>\n<n bytes>` frame from r and returns its payload.
+// Blank lines between frames are skipped, so producers can end payloads with newline.
+func readFrame(r *bufio.Reader) (string, error) {
+	var header string
+	for header == "" {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		header = strings.TrimSpace(line)
+	}
+
+	n, err := strconv.Atoi(header)
+	if err != nil || n < 0 || n > maxFrameSize {
+		return "", fmt.Errorf("invalid frame length `%s`", header)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// readFrames reads length-prefixed frames from r and sends text
+// scanned from every one of them to texts, until reading fails.
+// Every frame is a text of its own, with newlines in it starting new rows.
+// Reading can go on with the same r after a bad frame header,
+// from the line right after it.
+func readFrames(r *bufio.Reader, parser Parser, texts chan<- []*TextPiece) error {
+	for {
+		str, err := readFrame(r)
+		if err != nil {
+			return err
+		}
+		stats.linesParsed.Add(1)
+		texts <- parser.Scan(strings.NewReader(strings.TrimSuffix(str, "\n")))
+	}
+}
@@ -0,0 +1,101 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		input  string
+		frames []string
+		err    error
+	}{
+		{"5\ntest1", []string{"test1"}, io.EOF},
+		{"11\n{F1 }\n{F2 }\n", []string{"{F1 }\n{F2 }"}, io.EOF},
+		{"5\ntest1\n\n5\ntest2", []string{"test1", "test2"}, io.EOF},
+		{"0\n", []string{""}, io.EOF},
+		{"5\ntes", nil, io.ErrUnexpectedEOF},
+		{"x\ntest1", nil, errors.New("invalid frame length `x`")},
+		{"-1\n", nil, errors.New("invalid frame length `-1`")},
+	}
+
+	for i, test := range tests {
+		reader := bufio.NewReader(strings.NewReader(test.input))
+		var frames []string
+		var err error
+		for {
+			var frame string
+			if frame, err = readFrame(reader); err != nil {
+				break
+			}
+			frames = append(frames, frame)
+		}
+
+		assertEqual(t, test.input, test.frames, frames, "ReadFrame", i)
+		assertEqualError(t, test.err, err, "ReadFrame", i)
+	}
+}
+
+func TestReadFrames(t *testing.T) {
+	input := "12\ntest1\ntest2\n5\ntest3"
+	expected := [][]*TextPiece{
+		{{Text: "test1"}, {Text: "test2", Row: 1}},
+		{{Text: "test3"}},
+	}
+
+	texts := make(chan []*TextPiece, len(expected)+1)
+	parser := NewTextParser()
+	parser.MultiLine = true
+	err := readFrames(bufio.NewReader(strings.NewReader(input)), parser, texts)
+	close(texts)
+	assertEqualError(t, io.EOF, err, "ReadFrames", 0)
+
+	var actual [][]*TextPiece
+	for text := range texts {
+		actual = append(actual, text)
+	}
+	assertEqual(t, input, expected, actual, "ReadFrames", 0)
+}
+
+func TestReadFrames_badHeader(t *testing.T) {
+	input := "x\n5\ntest1"
+	expected := [][]*TextPiece{{{Text: "test1"}}}
+
+	texts := make(chan []*TextPiece, len(expected)+1)
+	reader := bufio.NewReader(strings.NewReader(input))
+	readUntilEOF(func() error {
+		return readFrames(reader, NewTextParser(), texts)
+	}, texts)
+
+	var actual [][]*TextPiece
+	for text := range texts {
+		actual = append(actual, text)
+	}
+	assertEqual(t, input, expected, actual, "ReadFrames_badHeader", 0)
+}
@@ -24,12 +24,25 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
+	"io"
 	"log"
+	"math"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/jezek/xgb/xproto"
 	"github.com/jezek/xgbutil"
@@ -37,17 +50,84 @@ import (
 	"github.com/jezek/xgbutil/xevent"
 	"github.com/jezek/xgbutil/xgraphics"
 	"github.com/jezek/xgbutil/xinerama"
+	"github.com/jezek/xgbutil/xrect"
 	"github.com/jezek/xgbutil/xwindow"
 	"golang.org/x/image/font"
+	"golang.org/x/image/font/inconsolata"
 	"golang.org/x/image/math/fixed"
 )
 
+// Exit codes used when terminating application because of an error.
+// Note that invalid flags make flag package exit with 2.
+const (
+	exitFailure   = 1
+	exitNoDisplay = 3
+)
+
+// NoDisplayError is raised when connection to X server cannot be made.
+type NoDisplayError struct {
+	Err error
+}
+
+func (e NoDisplayError) Error() string {
+	return fmt.Sprintf("no X display (%s); set DISPLAY or use -measure", e.Err)
+}
+
+func (e NoDisplayError) Unwrap() error { return e.Err }
+
+// exitCode returns code application should terminate with because of err.
+func exitCode(err error) int {
+	var noDisplay NoDisplayError
+	if errors.As(err, &noDisplay) {
+		return exitNoDisplay
+	}
+	return exitFailure
+}
+
+// exitWith logs given message and terminates application with given code.
+var exitWith = func(code int, msg string) {
+	log.Print(msg)
+	os.Exit(code)
+}
+
 // fatal is a helper function to call when something terribly wrong
 // had happened. Logs given error and terminates application.
 func fatal(err error) {
 	if err != nil {
-		log.Fatal(err)
+		exitWith(exitCode(err), err.Error())
+	}
+}
+
+// newConn connects to X. Replaceable for testing.
+var newConn = xgbutil.NewConn
+
+// sleep waits between connection attempts. Replaceable for testing.
+var sleep = time.Sleep
+
+// connect connects to X, retrying up to retries times if that fails.
+// Waits interval before the first retry and twice as long
+// before every next one.
+func connect(retries int, interval time.Duration) (*xgbutil.XUtil, error) {
+	X, err := newConn()
+	for i := 0; err != nil && i < retries; i++ {
+		logf(INFO, "Could not connect to X: %s, retrying in %s", err, interval)
+		sleep(interval)
+		interval *= 2
+		X, err = newConn()
+	}
+	return X, err
+}
+
+// expandPath expands leading ~ to the user home directory
+// and environment variables in path.
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
 	}
+	return path
 }
 
 func contains(slice []uint, item uint) bool {
@@ -74,6 +154,60 @@ func headsEqual(h1, h2 xinerama.Heads) bool {
 	return true
 }
 
+// spanHeads returns a single head covering all of heads.
+func spanHeads(heads xinerama.Heads) xinerama.Heads {
+	if len(heads) == 0 {
+		return heads
+	}
+	var span image.Rectangle
+	for _, head := range heads {
+		span = span.Union(image.Rect(head.X(), head.Y(), head.X()+head.Width(), head.Y()+head.Height()))
+	}
+	return xinerama.Heads{xrect.New(span.Min.X, span.Min.Y, span.Dx(), span.Dy())}
+}
+
+// wrapText splits text into lines, breaking at spaces.
+// First line has to fit into first width, every next into width.
+// Empty first line means that not even a single word fits there.
+// Words longer than width are broken wherever they need to be.
+func wrapText(face font.Face, text string, first, width fixed.Int26_6) []string {
+	var lines []string
+	line, limit := "", first
+	words := strings.SplitAfter(text, " ")
+	for i := 0; i < len(words); {
+		word := words[i]
+		if font.MeasureString(face, strings.TrimRight(line+word, " ")) <= limit {
+			line += word
+			i++
+			continue
+		}
+		if line == "" && limit >= width {
+			n := fitText(face, word, limit)
+			line, words[i] = word[:n], word[n:]
+			if words[i] == "" {
+				i++
+			}
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+		line, limit = "", width
+	}
+	return append(lines, line)
+}
+
+// fitText returns length of the longest prefix of text fitting into width.
+// It is at least one rune long, so that callers always make progress.
+func fitText(face font.Face, text string, width fixed.Int26_6) int {
+	n := 0
+	for i, r := range text {
+		if i > 0 && font.MeasureString(face, text[:i+utf8.RuneLen(r)]) > width {
+			break
+		}
+		n = i + utf8.RuneLen(r)
+	}
+	return n
+}
+
 // Position defines bar placement on the screen.
 type Position uint8
 
@@ -82,29 +216,206 @@ const (
 	TOP
 )
 
+// VAlign defines vertical text placement within the bar.
+type VAlign uint8
+
+const (
+	VCENTER VAlign = iota
+	VTOP
+	VBOTTOM
+)
+
+var vAlignNames = map[VAlign]string{
+	VCENTER: "center",
+	VTOP:    "top",
+	VBOTTOM: "bottom",
+}
+
+func (v *VAlign) String() string {
+	return vAlignNames[*v]
+}
+
+func (v *VAlign) Set(value string) error {
+	for align, name := range vAlignNames {
+		if name == value {
+			*v = align
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid vertical alignment `%s`", value)
+}
+
+// textY returns y coordinate to draw text at, so that it ends up
+// aligned within the row starting at y and of given height.
+// Takes into account the offset added by xgraphics.Image.Text.
+func textY(face font.Face, valign VAlign, y, height int) fixed.Int26_6 {
+	metrics := face.Metrics()
+
+	var baseline fixed.Int26_6
+	switch valign {
+	case VTOP:
+		baseline = metrics.Ascent
+	case VBOTTOM:
+		baseline = fixed.I(height) - metrics.Descent
+	default:
+		baseline = (fixed.I(height) + metrics.Ascent - metrics.Descent) / 2
+	}
+	return fixed.I(y) + baseline - (metrics.Height - fixed.I(metrics.CaretSlope.Y*2))
+}
+
 // Geometry stores bars geometry on the screen (or actually monitor).
+// Y is an offset from the screen edge the bar is placed at,
+// or from the opposite one, if negative.
 type Geometry struct {
 	Width  uint16
 	Height uint16
 	X      uint16
-	Y      uint16
+	Y      int16
 }
 
 func (g *Geometry) String() string {
-	return fmt.Sprintf("%dx%d+%d+%d", g.Width, g.Height, g.X, g.Y)
+	return fmt.Sprintf("%dx%d+%d%+d", g.Width, g.Height, g.X, g.Y)
+}
+
+// Options stores optional Bar configuration.
+type Options struct {
+	// Rows is a number of text rows stacked within each bar.
+	// Values below 1 mean a single row.
+	Rows int
+	// Wrap makes left aligned text overflowing the bar width
+	// continue in the next row.
+	Wrap bool
+	// VAlign is a vertical placement of text within each row.
+	VAlign VAlign
+	// AutoHide keeps the bar hidden until pointer reaches the screen
+	// edge it is docked at. It hides again AutoHideDelay after
+	// the pointer leaves.
+	AutoHide      bool
+	AutoHideDelay time.Duration
+	// HideOnFullscreen keeps the bar below other windows and hides it
+	// from the head that active fullscreen window is on.
+	HideOnFullscreen bool
+	// Border is a width of border drawn around each bar, in pixels.
+	Border      int
+	BorderColor *xgraphics.BGRA
+	// BgImage, if set, covers the bar instead of plain Background color,
+	// either stretched or tiled, according to BgImageMode.
+	BgImage     image.Image
+	BgImageMode BgMode
+	// Dither makes BgImage composed with higher precision
+	// and dithered down, to avoid banding.
+	Dither bool
+	// Tracking is a number of pixels added between glyphs.
+	Tracking int
+	// TabWidth is a distance between tab stops, in pixels.
+	// Zero leaves tabs to the font.
+	TabWidth int
+	// NoAntialias draws text without antialiasing.
+	NoAntialias bool
+	// TextOutline, if set, is a color of halo drawn around text,
+	// unless pieces set their own.
+	TextOutline *xgraphics.BGRA
+	// HoverHighlight, if set, is blended over pieces pointer is over.
+	HoverHighlight *xgraphics.BGRA
+	// HoverReport, if set, gets name of the piece pointer is over
+	// written to it, whenever that changes.
+	HoverReport io.Writer
+	// RespectStruts places the bar next to space reserved by other docks,
+	// instead of at the very edge of the head.
+	RespectStruts bool
+	// Baselines are offsets text is drawn at, in pixels, per font.
+	Baselines []int
+	// AccentLine is drawn along the bar edge facing away from
+	// the screen edge, if it has a color.
+	AccentLine AccentLine
+	// Mono advances every character by the same cell width, that of
+	// 0 in the first font, so that text lines up like in a terminal.
+	Mono bool
+	// FitHeight scales fonts looked up by name down to fit
+	// into bar rows, instead of clipping their glyphs.
+	FitHeight bool
+	// EdgeBleed extends backgrounds of the outermost left and right
+	// pieces of every row to the bar edges.
+	EdgeBleed bool
+	// Single makes one bar window span all the heads.
+	Single bool
+	// Gap insets the bar from head edges, horizontally and vertically.
+	Gap image.Point
+	// ClickThrough makes pointer events pass through the bar.
+	ClickThrough bool
+	// ClickCopy makes clicking a piece copy it to the clipboard.
+	ClickCopy bool
+	// NoEWMH creates plain override-redirect windows, without dock
+	// window type, states or struts, for window managers not handling
+	// these well.
+	NoEWMH bool
+	// OverrideRedirect keeps window manager from managing bar windows,
+	// so that they stay exactly where they are placed.
+	OverrideRedirect bool
+	// UrgentColor, if set, is the color backgrounds of urgent pieces
+	// pulse towards, once every UrgentPeriod.
+	UrgentColor  *xgraphics.BGRA
+	UrgentPeriod time.Duration
+	// Window, if set, is an existing window the bar is drawn into,
+	// instead of creating dock windows.
+	Window xproto.Window
 }
 
 // Bar stores and manages all X related stuff and configuration.
 type Bar struct {
+	Options
+
 	X          *xgbutil.XUtil
 	Windows    []*xwindow.Window
 	Geometries []*Geometry
-	Foreground *xgraphics.BGRA
-	Background *xgraphics.BGRA
+	Foreground colors
+	Background colors
 	Colors     []*xgraphics.BGRA
 	Fonts      fonts
 
-	heads xinerama.Heads
+	// drawer creates windows and paints onto them.
+	drawer drawer
+	heads  xinerama.Heads
+	// geometries are requested per head geometries, with Geometries
+	// being the ones bar windows actually got.
+	geometries []*Geometry
+	position   Position
+	struts     []barStruts
+	rects      []image.Rectangle
+	canvases   []*canvas
+	bgCache    map[bgKey]*xgraphics.Image
+	badFonts   map[uint]bool
+	fontCache  map[fontKey]font.Face
+	// tallFaces stores faces already warned about by warnTall.
+	tallFaces map[font.Face]bool
+	// wrappedFaces stores faces wrapped according to Tracking
+	// and NoAntialias, so that they keep their identity between frames.
+	wrappedFaces map[font.Face]font.Face
+	widths       *widthCache
+	// lastText is the most recently drawn text,
+	// and lastPieces are the pieces it got resolved to.
+	lastText   []*TextPiece
+	lastPieces []*drawPiece
+	// redraws receives requests to draw lastText again.
+	redraws chan struct{}
+
+	hiddenWindows []bool
+	toggled       bool
+	autoHidden    bool
+	lastNear      time.Time
+	fullscreen    image.Rectangle
+	hoveredName   string
+	// copied are texts last copied by clicking a piece,
+	// by selections the bar still owns.
+	copied map[xproto.Atom]string
+	// spinFrame is the frame spinners are at.
+	spinFrame int
+	// cell is the width characters advance by with Mono,
+	// 0 until it is known.
+	cell fixed.Int26_6
+	// urgentLevel is how far urgent pieces are into UrgentColor,
+	// from 0 to 1, in the frame being drawn.
+	urgentLevel float64
 }
 
 // NewBar creates X windows for every monitor.
@@ -112,60 +423,173 @@ type Bar struct {
 // deals with dynamic geometry changes.
 func NewBar(
 	X *xgbutil.XUtil, geometries []*Geometry, position Position,
-	fg uint64, bg uint64, fonts fonts,
+	fg colors, bg colors, fonts fonts, opts Options,
 ) *Bar {
 	heads, err := xinerama.PhysicalHeads(X)
 	fatal(err)
+	if opts.Single {
+		heads = spanHeads(heads)
+	}
 
 	bar := &Bar{
+		Options:    opts,
 		X:          X,
+		drawer:     &xDrawer{X},
 		Windows:    []*xwindow.Window{},
 		Geometries: []*Geometry{},
-		Foreground: NewBGRA(fg),
-		Background: NewBGRA(bg),
+		Foreground: fg,
+		Background: bg,
 		Fonts:      fonts,
 		heads:      heads,
+		geometries: geometries,
+		position:   position,
+		autoHidden: opts.AutoHide,
+		redraws:    make(chan struct{}, 1),
 	}
 
 	bar.create(geometries, position)
 
+	rootMask := uint32(xproto.EventMaskStructureNotify)
+	if opts.HideOnFullscreen {
+		rootMask |= xproto.EventMaskPropertyChange
+	}
 	xproto.ChangeWindowAttributesChecked(
-		X.Conn(), X.RootWin(), xproto.CwEventMask,
-		[]uint32{xproto.EventMaskStructureNotify},
+		X.Conn(), X.RootWin(), xproto.CwEventMask, []uint32{rootMask},
 	)
 	xevent.ConfigureNotifyFun(func(_ *xgbutil.XUtil, _ xevent.ConfigureNotifyEvent) {
 		heads, err = xinerama.PhysicalHeads(X)
 		if err != nil {
-			log.Printf("Error `%s` getting updated heads, staying with the old ones\n", err)
+			logf(ERROR, "Error `%s` getting updated heads, staying with the old ones\n", err)
 			return
 		}
+		if opts.Single {
+			heads = spanHeads(heads)
+		}
 		if !headsEqual(heads, bar.heads) {
 			bar.destroy()
 			bar.heads = heads
-			bar.create(geometries, position)
+			bar.create(bar.geometries, position)
+			// Do not leave new windows blank until the next input line.
+			bar.requestRedraw()
 		}
 	}).Connect(X, X.RootWin())
 
+	if opts.HideOnFullscreen {
+		bar.watchFullscreen()
+	}
+
 	return bar
 }
 
+// resize creates bar windows again, with given height on every head.
+// Whatever was drawn last is drawn again, once they are recreated.
+func (b *Bar) resize(height uint16) {
+	if b.Window != 0 {
+		logf(WARN, "Cannot resize window `0x%x` gobar is embedded into", b.Window)
+		return
+	}
+	b.geometries = resizedGeometries(b.geometries, height)
+	b.destroy()
+	b.create(b.geometries, b.position)
+	b.requestRedraw()
+}
+
+// resizedGeometries returns copies of geometries with given height.
+// Heads without geometry stay without. No geometries at all stand
+// for bars on every head, so they get one with the height.
+func resizedGeometries(geometries []*Geometry, height uint16) []*Geometry {
+	if len(geometries) == 0 {
+		return []*Geometry{{Height: height}}
+	}
+	resized := make([]*Geometry, len(geometries))
+	for i, geometry := range geometries {
+		if geometry != nil {
+			g := *geometry
+			g.Height = height
+			resized[i] = &g
+		}
+	}
+	return resized
+}
+
 // destroy Destroys all existing windows and resets geometries.
 func (b *Bar) destroy() {
 	for i, window := range b.Windows {
-		window.Destroy()
+		// Embedding window is not ours to destroy.
+		b.drawer.destroyWindow(window.Id, b.Window == 0)
 		b.Windows[i] = nil
 	}
 	b.Windows = []*xwindow.Window{}
 	b.Geometries = []*Geometry{}
+	b.struts = nil
+	b.rects = nil
+	b.hiddenWindows = nil
+	for _, canvas := range b.canvases {
+		canvas.img.Destroy()
+	}
+	b.canvases = nil
 }
 
-func (b *Bar) create(geometries []*Geometry, position Position) {
-	maxHeight := xwindow.RootGeometry(b.X).Height()
+// struts computes EWMH struts reserving space for a bar window
+// of given size, placed at x and y relative to the head.
+func struts(
+	head xrect.Rect, x, y, width, height, maxHeight int, position Position,
+) (*ewmh.WmStrutPartial, *ewmh.WmStrut) {
+	strutP := &ewmh.WmStrutPartial{}
+	strut := &ewmh.WmStrut{}
+
+	// Struts are relative to the root window, not to the head.
+	startX := uint(head.X() + x)
+	endX := uint(head.X() + x + width)
+	if position == BOTTOM {
+		bottom := uint(maxHeight - y)
+
+		strutP.BottomStartX = startX
+		strutP.BottomEndX = endX
+		strutP.Bottom = bottom
+		strut.Bottom = bottom
+	} else {
+		strutP.TopStartX = startX
+		strutP.TopEndX = endX
+		strutP.Top = uint(height)
+		strut.Top = uint(height)
+	}
+	return strutP, strut
+}
+
+// Close destroys all existing windows, releasing their struts,
+// and closes X connection, making sure that everything was sent.
+func (b *Bar) Close() {
+	b.destroy()
+	b.X.Sync()
+	b.X.Conn().Close()
+}
 
+// placement describes where bar window goes on a head.
+type placement struct {
+	head int
+	// rect is the window area, in root window coordinates.
+	rect image.Rectangle
+	// geometry is the window geometry, with y relative to the head.
+	geometry *Geometry
+	struts   barStruts
+}
+
+// placements computes where bar windows go on heads, given geometries
+// and height of the root window. Heads without geometry get no bar.
+// Bars are placed next to the space already reserved on every head
+// by struts of docks, if there are any.
+// Bars are inset from head edges by gap, while their struts
+// reserve the gap on both sides of the bar as well.
+func placements(
+	heads xinerama.Heads, geometries []*Geometry, position Position,
+	maxHeight int, docks []ewmh.WmStrutPartial, gap image.Point,
+) []placement {
 	if len(geometries) == 0 {
-		geometries = append(geometries, &Geometry{Height: 16})
+		geometries = append(geometries, &Geometry{Height: defaultHeight})
 	}
-	for i, head := range b.heads {
+	var result []placement
+	for i, head := range heads {
 		var geometry *Geometry
 		if i >= len(geometries) {
 			if geometries[len(geometries)-1] == nil {
@@ -178,263 +602,2178 @@ func (b *Bar) create(geometries []*Geometry, position Position) {
 			}
 			geometry = geometries[i]
 		}
-		win, err := xwindow.Generate(b.X)
-		if err != nil {
-			log.Printf("Could not generate window for geometry `%s`", geometry)
-			continue
-		}
 
 		width := int(geometry.Width)
 		if width == 0 {
-			width = head.Width()
+			width = head.Width() - 2*gap.X
 		}
 		height := int(geometry.Height)
 		if height == 0 {
-			height = head.Height()
+			height = head.Height() - 2*gap.Y
 		}
-		y := int(geometry.Y)
-
-		strutP := ewmh.WmStrutPartial{}
-		strut := ewmh.WmStrut{}
-		if position == BOTTOM {
-			y = head.Height() - height - y
-			bottom := uint(maxHeight - y)
-
-			strutP.BottomStartX = uint(geometry.X)
-			strutP.BottomEndX = uint(geometry.X + uint16(width))
-			strutP.Bottom = bottom
-			strut.Bottom = bottom
-		} else {
-			strutP.TopStartX = uint(geometry.X)
-			strutP.TopEndX = uint(geometry.X + uint16(width))
-			strutP.Top = uint(height)
-			strut.Top = uint(height)
+		if width < 1 || height < 1 {
+			logf(WARN, "Gaps `%dx%d` leave no room for the bar on monitor %d, using at least one pixel",
+				gap.X, gap.Y, i)
+			if width < 1 {
+				width = 1
+			}
+			if height < 1 {
+				height = 1
+			}
+		}
+		// Space reserved by other docks at the top and bottom of the head.
+		top, bottom := reservedSpace(head, docks, maxHeight)
+		// Negative offset counts from the opposite edge,
+		// so that is the edge the bar ends up at.
+		edge, offset := position, int(geometry.Y)
+		if offset < 0 {
+			edge, offset = BOTTOM, -offset
+			if position == BOTTOM {
+				edge = TOP
+			}
 		}
+		y := offset + top + gap.Y
+		strutY, strutHeight := y, height+top+2*gap.Y
 
-		win.Create(
-			b.X.RootWin(),
-			int(geometry.X)+head.X(),
-			y+head.Y(),
-			width, height, 0,
-		)
+		if edge == BOTTOM {
+			y = head.Height() - height - offset - bottom - gap.Y
+			strutY = y - gap.Y
+		}
+		// Struts do not add up, so the bar has to reserve space
+		// for the docks above it as well.
+		strutP, strut := struts(head, int(geometry.X)+gap.X, strutY, width, strutHeight, maxHeight, edge)
 
-		ewmh.WmWindowTypeSet(b.X, win.Id, []string{"_NET_WM_WINDOW_TYPE_DOCK"})
-		ewmh.WmStateSet(b.X, win.Id, []string{"_NET_WM_STATE_STICKY"})
-		ewmh.WmDesktopSet(b.X, win.Id, 0xFFFFFFFF)
-		ewmh.WmStrutPartialSet(b.X, win.Id, &strutP)
-		ewmh.WmStrutSet(b.X, win.Id, &strut)
-
-		b.Windows = append(b.Windows, win)
-		b.Geometries = append(b.Geometries, &Geometry{
-			X:      geometry.X,
-			Y:      uint16(y),
-			Width:  uint16(width),
-			Height: uint16(height),
+		x := int(geometry.X) + gap.X + head.X()
+		result = append(result, placement{
+			head: i,
+			rect: image.Rect(x, y+head.Y(), x+width, y+head.Y()+height),
+			geometry: &Geometry{
+				X:      geometry.X,
+				Y:      int16(y),
+				Width:  uint16(width),
+				Height: uint16(height),
+			},
+			struts: barStruts{strutP, strut},
 		})
 	}
+	return result
 }
 
-// Draw draws TextPieces into X monitors.
-func (b *Bar) Draw(text []*TextPiece) {
-	imgs := make([]*xgraphics.Image, len(b.Windows))
-	for i, geometry := range b.Geometries {
-		imgs[i] = xgraphics.New(b.X, image.Rect(
-			0, 0, int(geometry.Width), int(geometry.Height),
-		))
-		imgs[i].For(func(x, y int) xgraphics.BGRA { return *b.Background })
-	}
-
-	xsl := make([]fixed.Int26_6, len(b.Windows))
-	xsr := make([]fixed.Int26_6, len(b.Windows))
-	for i := range xsr {
-		xsr[i] = fixed.I(int(b.Geometries[i].Width))
+// reservedSpace returns how much space at the top and bottom of head
+// is reserved by struts of docks. Struts count from the edges
+// of the root window, which is maxHeight high.
+func reservedSpace(head xrect.Rect, docks []ewmh.WmStrutPartial, maxHeight int) (int, int) {
+	var top, bottom int
+	// Strut ranges include both of their ends.
+	covers := func(start, end uint) bool {
+		return int(start) < head.X()+head.Width() && int(end) >= head.X()
 	}
-	for _, piece := range text {
-		if piece.Background == nil {
-			piece.Background = b.Background
+	for _, dock := range docks {
+		if dock.Top > 0 && covers(dock.TopStartX, dock.TopEndX) {
+			if reserved := int(dock.Top) - head.Y(); reserved > top {
+				top = reserved
+			}
 		}
-		if piece.Foreground == nil {
-			piece.Foreground = b.Foreground
+		if dock.Bottom > 0 && covers(dock.BottomStartX, dock.BottomEndX) {
+			if reserved := int(dock.Bottom) - (maxHeight - head.Y() - head.Height()); reserved > bottom {
+				bottom = reserved
+			}
 		}
+	}
+	return top, bottom
+}
 
-		if piece.Font > uint(len(b.Fonts))-1 {
-			log.Printf("Invalid font index `%d`, using `0`", piece.Font)
-			piece.Font = 0
+// overlapping returns pairs of indexes of places,
+// which bar windows overlap each other.
+func overlapping(places []placement) [][2]int {
+	var pairs [][2]int
+	for i := range places {
+		for j := i + 1; j < len(places); j++ {
+			if places[i].rect.Overlaps(places[j].rect) {
+				pairs = append(pairs, [2]int{i, j})
+			}
 		}
-		pFont := b.Fonts[piece.Font]
-		width := font.MeasureString(pFont, piece.Text)
+	}
+	return pairs
+}
 
-		screens := []uint{}
-		if piece.Screens == nil {
-			for i := range imgs {
-				if !contains(piece.NotScreens, uint(i)) {
-					screens = append(screens, uint(i))
-				}
-			}
-		} else {
-			for _, screen := range piece.Screens {
-				if int(screen) < len(xsl) && !contains(piece.NotScreens, screen) {
-					screens = append(screens, screen)
-				}
+// warnOverlapping logs a warning for every pair of bar windows that overlap,
+// as they would be drawn over each other and reserve space twice.
+func warnOverlapping(places []placement) {
+	for _, pair := range overlapping(places) {
+		first, second := places[pair[0]], places[pair[1]]
+		logf(WARN, "Bars on monitors %d and %d overlap at `%s`, check geometries",
+			first.head, second.head, first.rect.Intersect(second.rect))
+	}
+}
+
+// printHeads writes <head>\t<x>,<y>,<w>,<h>\t<x>,<y>,<w>,<h> line to w
+// for every head, describing its area and area of its bar window,
+// or - for the latter, if there is no bar on the head.
+func printHeads(w io.Writer, heads xinerama.Heads, places []placement) error {
+	for i, head := range heads {
+		bar := "-"
+		for _, place := range places {
+			if place.head == i {
+				r := place.rect
+				bar = fmt.Sprintf("%d,%d,%d,%d", r.Min.X, r.Min.Y, r.Dx(), r.Dy())
 			}
 		}
+		_, err := fmt.Fprintf(w, "%d\t%d,%d,%d,%d\t%s\n",
+			i, head.X(), head.Y(), head.Width(), head.Height(), bar,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		for _, screen := range screens {
-			xs := xsl[screen]
-			if piece.Align == RIGHT {
-				xs = xsr[screen] - width
-			}
-
-			// XXX Avoid the roundings?
-			// Would waterfall inside xgraphics and create problems with adhering
-			// to the image.Image interface.
-			subimg := imgs[screen].SubImage(image.Rect(
-				xs.Round(), 0, (xs + width).Round(), int(b.Geometries[screen].Height),
-			))
-			if subimg == nil {
-				log.Printf(
-					"Cannot create Subimage for coords `%dx%dx%dx%d`\n",
-					xs, 0, xs+width, int(b.Geometries[screen].Height),
-				)
-				continue
+func (b *Bar) create(geometries []*Geometry, position Position) {
+	if b.Window != 0 {
+		b.embed()
+	} else {
+		b.createDocks(geometries, position)
+	}
+
+	b.createCanvases()
+	for i, canvas := range b.canvases {
+		b.drawer.surface(canvas.img, b.Windows[i].Id)
+		if events := b.windowEvents(); events != xproto.EventMaskNoEvent {
+			b.drawer.listen(b.Windows[i].Id, events)
+		}
+		if b.HoverHighlight != nil || b.HoverReport != nil {
+			b.listenHover(i)
+		}
+		if b.ClickCopy && b.Window == 0 {
+			b.listenClicks(i)
+		}
+		if b.ClickThrough && b.Window == 0 {
+			if err := clickThrough(b.X, b.Windows[i].Id); err != nil {
+				logf(ERROR, "Could not make bar click-through: %s", err)
 			}
-			subximg := subimg.(*xgraphics.Image)
+		}
+	}
+	if len(b.copied) > 0 && len(b.Windows) > 0 {
+		b.ownSelections(b.Windows[0].Id)
+	}
+}
 
-			subximg.For(func(x, y int) xgraphics.BGRA { return *piece.Background })
+// embed makes the bar drawn into Window, filling it whole.
+func (b *Bar) embed() {
+	rect, err := b.drawer.windowRect(b.Window)
+	if err != nil {
+		fatal(fmt.Errorf("cannot embed into window `0x%x`: %s", b.Window, err))
+	}
 
-			xsNew := subximg.Text(fixed.Point26_6{X: xs, Y: 0}, piece.Foreground, pFont, piece.Text).X
+	b.Windows = append(b.Windows, xwindow.New(b.X, b.Window))
+	b.rects = append(b.rects, rect)
+	b.struts = append(b.struts, barStruts{})
+	b.hiddenWindows = append(b.hiddenWindows, b.windowHidden(0))
+	b.Geometries = append(b.Geometries, &Geometry{
+		Width:  uint16(rect.Dx()),
+		Height: uint16(rect.Dy()),
+	})
 
-			if piece.Align == LEFT {
-				xsl[screen] = xsNew
-			} else if piece.Align == RIGHT {
-				xsr[screen] -= width
-			}
+	// Connected again on every embedding, as destroy detaches it.
+	xevent.ConfigureNotifyFun(func(_ *xgbutil.XUtil, ev xevent.ConfigureNotifyEvent) {
+		if int(ev.Width) == b.rects[0].Dx() && int(ev.Height) == b.rects[0].Dy() {
+			return
+		}
+		b.destroy()
+		b.create(nil, b.position)
+		b.requestRedraw()
+	}).Connect(b.X, b.Window)
+}
 
-			subximg.XPaint(b.Windows[screen].Id)
-			subximg.Destroy()
+// dockStruts returns struts of all the windows managed by window manager
+// that reserve any space, with plain struts spanning whole root width.
+// Windows already gone, e.g. ones bar has just destroyed, are skipped.
+func dockStruts(X *xgbutil.XUtil) []ewmh.WmStrutPartial {
+	clients, err := ewmh.ClientListGet(X)
+	if err != nil {
+		logf(WARN, "Could not get client list, ignoring other docks: %v", err)
+		return nil
+	}
+	var docks []ewmh.WmStrutPartial
+	for _, client := range clients {
+		if strutP, err := ewmh.WmStrutPartialGet(X, client); err == nil {
+			docks = append(docks, *strutP)
+		} else if strut, err := ewmh.WmStrutGet(X, client); err == nil {
+			docks = append(docks, ewmh.WmStrutPartial{
+				Top: strut.Top, TopEndX: math.MaxInt32,
+				Bottom: strut.Bottom, BottomEndX: math.MaxInt32,
+			})
 		}
 	}
+	return docks
+}
 
-	for i, img := range imgs {
-		img.XSurfaceSet(b.Windows[i].Id)
-		img.XDraw()
-		img.XPaint(b.Windows[i].Id)
-		img.Destroy()
+// createDocks creates dock window for every head with geometry.
+func (b *Bar) createDocks(geometries []*Geometry, position Position) {
+	maxHeight := b.drawer.rootHeight()
 
-		b.Windows[i].Map()
+	var docks []ewmh.WmStrutPartial
+	if b.RespectStruts {
+		docks = b.drawer.docks()
 	}
-}
+	places := placements(b.heads, geometries, position, maxHeight, docks, b.Gap)
+	warnOverlapping(places)
+	for _, place := range places {
+		rect := place.rect
+		mask, values := b.windowAttributes()
+		win, err := b.drawer.createWindow(rect, mask, values)
+		if err != nil {
+			logf(ERROR, "Could not generate window for geometry `%s`", place.geometry)
+			continue
+		}
+		if !b.NoEWMH {
+			b.setDockHints(win)
+		}
 
-type fonts []font.Face
+		b.Windows = append(b.Windows, xwindow.New(b.X, win))
+		b.rects = append(b.rects, rect)
+		b.struts = append(b.struts, place.struts)
+		b.hiddenWindows = append(b.hiddenWindows, b.windowHidden(len(b.Windows)-1))
+		b.setStruts(len(b.Windows) - 1)
 
-func (f *fonts) String() string {
-	str := make([]string, len(*f))
-	for i, f := range *f {
-		str[i] = fmt.Sprintf("%v", f)
+		b.Geometries = append(b.Geometries, place.geometry)
 	}
-	return fmt.Sprintf("%q", strings.Join(str, ","))
 }
 
-func (f *fonts) Set(value string) error {
-	names := strings.Split(value, ",")
-	for _, name := range names {
-		font := findFont(name)
-		*f = append(*f, font)
+// windowAttributes returns value mask and values bar windows
+// are created with. Override-redirect windows get their event mask
+// in the same request, so that no events sent before they are listened
+// to are missed.
+func (b *Bar) windowAttributes() (int, []uint32) {
+	if !b.OverrideRedirect && !b.NoEWMH {
+		return 0, nil
 	}
-	return nil
+	return xproto.CwOverrideRedirect | xproto.CwEventMask, []uint32{1, b.windowEvents()}
 }
 
-type Geometries []*Geometry
+// windowEvents returns mask of events bar windows listen to.
+// Only one client can listen for button presses on a window,
+// so embedding window is left to its owner for these,
+// while its resizes are listened to instead.
+func (b *Bar) windowEvents() uint32 {
+	events := uint32(xproto.EventMaskNoEvent)
+	if b.HoverHighlight != nil || b.HoverReport != nil {
+		events |= xproto.EventMaskPointerMotion | xproto.EventMaskLeaveWindow
+	}
+	if b.Window != 0 {
+		events |= xproto.EventMaskStructureNotify
+	} else if b.ClickCopy {
+		events |= xproto.EventMaskButtonPress
+	}
+	return events
+}
 
-func (g *Geometries) String() string {
-	str := make([]string, len(*g))
-	for i, g := range *g {
-		str[i] = g.String()
+// setDockHints tells window manager that win is a dock,
+// visible on all desktops.
+func (b *Bar) setDockHints(win xproto.Window) {
+	states := []string{"_NET_WM_STATE_STICKY"}
+	if b.HideOnFullscreen {
+		states = append(states, "_NET_WM_STATE_BELOW")
 	}
-	j := strings.Join(str, ",")
-	if j == "" {
-		j = "0x16+0+0"
+	b.drawer.setDockHints(win, states)
+}
+
+// canvas stores buffers used for composing a screen,
+// retained between frames to avoid reallocating them on every Draw.
+type canvas struct {
+	img *xgraphics.Image
+	// bg is drawn as background instead of a plain color, if not nil.
+	bg *xgraphics.Image
+	// xsl, xsr and xsc are per row positions of left, right
+	// and center aligned text.
+	xsl []fixed.Int26_6
+	xsr []fixed.Int26_6
+	xsc []fixed.Int26_6
+	// spans are areas covered by pieces, in order they were drawn.
+	spans []image.Rectangle
+	// spanPieces are pieces drawn in spans, in the same order.
+	spanPieces []*TextPiece
+	// pointer is a position of pointer within the screen,
+	// valid only when hovering.
+	pointer  image.Point
+	hovering bool
+}
+
+// createCanvases creates canvas for every screen geometry.
+func (b *Bar) createCanvases() {
+	rows := b.rows()
+	b.canvases = make([]*canvas, len(b.Geometries))
+	for i, geometry := range b.Geometries {
+		size := image.Pt(int(geometry.Width), int(geometry.Height))
+		b.canvases[i] = &canvas{
+			img: xgraphics.New(b.X, image.Rectangle{Max: size}),
+			xsl: make([]fixed.Int26_6, rows),
+			xsr: make([]fixed.Int26_6, rows),
+			xsc: make([]fixed.Int26_6, rows),
+		}
+		if b.BgImage != nil {
+			b.canvases[i].bg = b.background(size, b.Background.at(uint(i)))
+		}
 	}
-	return fmt.Sprintf("%q", j)
 }
 
-func (g *Geometries) Set(value string) error {
-	if len(*g) > 0 {
-		return fmt.Errorf("geometries flag already set")
+// face returns font face with given index.
+// Invalid indices fall back to the first font (or to the bundled one,
+// if there are no fonts at all), logging only once per index.
+func (b *Bar) face(index uint) font.Face {
+	if index < uint(len(b.Fonts)) {
+		return b.Fonts[index]
 	}
-	if value == "" {
-		return nil
+	if b.badFonts == nil {
+		b.badFonts = map[uint]bool{}
 	}
-	for _, geometry := range strings.Split(value, ",") {
-		if geometry == "" {
-			*g = append(*g, nil)
-		} else {
-			geom := &Geometry{}
-			_, err := fmt.Sscanf(
-				geometry, "%dx%d+%d+%d",
-				&geom.Width, &geom.Height, &geom.X, &geom.Y,
-			)
-			if err != nil {
-				geom = &Geometry{Height: 16}
-				log.Printf("Bad geometry `%s`, using default", geometry)
-			}
-			*g = append(*g, geom)
+	if len(b.Fonts) == 0 {
+		if !b.badFonts[index] {
+			logf(WARN, "No fonts available for index `%d`, using `inconsolata regular 8x16`", index)
+			b.badFonts[index] = true
 		}
+		return inconsolata.Regular8x16
 	}
-	return nil
+	if !b.badFonts[index] {
+		logf(WARN, "Invalid font index `%d`, using `0`", index)
+		b.badFonts[index] = true
+	}
+	return b.Fonts[0]
 }
 
-// main gets command line arguments, creates X connection and initializes Bar.
-// This is also where X event loop and Stdin reading lies.
-func main() {
-	bottom := flag.Bool("bottom", false, "Place bar at the bottom of the screen")
-	fgColor := flag.Uint64("fg", 0xFFFFFFFF, "Foreground color (0xAARRGGBB)")
-	flag.Lookup("fg").DefValue = "0xFFFFFFFF"
-	bgColor := flag.Uint64("bg", 0xFF000000, "Background color (0xAARRGGBB)")
-	flag.Lookup("bg").DefValue = "0xFF000000"
-	var fonts fonts
-	flag.Var(&fonts, "fonts", "Comma separated list of fonts in form of path[:size]")
-	var geometries Geometries
-	flag.Var(&geometries, "geometries", "Comma separated list of monitor geometries (<w>x<h>+<x>+<y>), for <w> and <h>, 0 means 100%")
-	flag.Parse()
+// baseline returns offset text of the piece is drawn at,
+// according to Baselines of its font.
+func (b *Bar) baseline(piece *TextPiece) int {
+	if piece.FontName == "" && piece.Font < uint(len(b.Baselines)) {
+		return b.Baselines[piece.Font]
+	}
+	return 0
+}
 
-	if len(fonts) < 1 {
-		fonts = append(fonts, findFontFallback("", 12))
+// pieceFace returns font face the piece should be drawn with,
+// spaced out according to Mono and Tracking, aliased according to
+// NoAntialias and with missing glyphs handled by missingGlyphFace.
+func (b *Bar) pieceFace(piece *TextPiece) font.Face {
+	face := b.lookupFace(piece)
+	if wrapped, ok := b.wrappedFaces[face]; ok {
+		return wrapped
+	}
+	if b.wrappedFaces == nil {
+		b.wrappedFaces = map[font.Face]font.Face{}
+	}
+	var wrapped font.Face = &missingGlyphFace{face}
+	if b.Mono {
+		wrapped = &cellFace{wrapped, b.cellWidth()}
+	}
+	if b.Tracking != 0 {
+		wrapped = &trackedFace{wrapped, fixed.I(b.Tracking)}
 	}
+	if b.NoAntialias {
+		wrapped = &aliasedFace{wrapped}
+	}
+	b.wrappedFaces[face] = wrapped
+	return wrapped
+}
 
-	position := TOP
-	if *bottom {
-		position = BOTTOM
+// cellWidth returns width of a cell characters advance by with Mono,
+// i.e. advance of 0 in the first font, rounded to whole pixels.
+func (b *Bar) cellWidth() fixed.Int26_6 {
+	if b.cell == 0 {
+		face := b.face(0)
+		advance, ok := face.GlyphAdvance('0')
+		if !ok || advance <= 0 {
+			advance = fixed.I(faceHeight(face) / 2)
+		}
+		b.cell = fixed.I(advance.Round())
 	}
+	return b.cell
+}
 
-	X, err := xgbutil.NewConn()
-	fatal(err)
+// cellFace advances every glyph by the same cell width, without kerning,
+// so that characters of any font line up in a grid. Glyphs are drawn
+// where they would start anyway, even if they are wider than a cell.
+type cellFace struct {
+	font.Face
+	cell fixed.Int26_6
+}
 
-	bar := NewBar(X, geometries, position, *fgColor, *bgColor, fonts)
-	parser := NewTextParser()
+func (f *cellFace) Glyph(
+	dot fixed.Point26_6, r rune,
+) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	dr, mask, maskp, _, ok := f.Face.Glyph(dot, r)
+	return dr, mask, maskp, f.cell, ok
+}
 
-	stdin := make(chan []*TextPiece)
-	go func() {
-		defer close(stdin)
-		reader := bufio.NewReader(os.Stdin)
+func (f *cellFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	bounds, _, ok := f.Face.GlyphBounds(r)
+	return bounds, f.cell, ok
+}
 
-		for {
-			str, err := reader.ReadString('\n')
-			if err != nil {
-				log.Printf("Error reading stdin. Got `%s`", err)
-			} else {
-				stdin <- parser.Scan(strings.NewReader(str))
+func (f *cellFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	_, ok := f.Face.GlyphAdvance(r)
+	return f.cell, ok
+}
+
+func (f *cellFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+// trackedFace adds constant space between every pair of glyphs.
+// Kerning is applied between glyphs both when drawing and measuring
+// text, so it is the single place that needs to change.
+type trackedFace struct {
+	font.Face
+	tracking fixed.Int26_6
+}
+
+func (f *trackedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return f.Face.Kern(r0, r1) + f.tracking
+}
+
+// missingGlyphFace keeps drawing and measuring of glyphs the font lacks
+// in agreement. Missing whitespace is drawn as a plain space, instead of
+// the box fonts draw in place of missing glyphs. Other missing glyphs,
+// which the face would skip when drawing, still take up the space
+// they were measured with, so text following them does not overlap them.
+type missingGlyphFace struct {
+	font.Face
+}
+
+func (f *missingGlyphFace) Glyph(
+	dot fixed.Point26_6, r rune,
+) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	if f.missingSpace(r) {
+		return image.Rectangle{}, nil, image.Point{}, f.spaceAdvance(), true
+	}
+	dr, mask, maskp, advance, ok := f.Face.Glyph(dot, r)
+	if !ok {
+		advance, _ = f.Face.GlyphAdvance(r)
+		return image.Rectangle{}, nil, image.Point{}, advance, true
+	}
+	return dr, mask, maskp, advance, ok
+}
+
+func (f *missingGlyphFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	if f.missingSpace(r) {
+		return fixed.Rectangle26_6{}, f.spaceAdvance(), true
+	}
+	return f.Face.GlyphBounds(r)
+}
+
+func (f *missingGlyphFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	if f.missingSpace(r) {
+		return f.spaceAdvance(), true
+	}
+	return f.Face.GlyphAdvance(r)
+}
+
+// missingSpace checks whether r is whitespace the font has no glyph for.
+// Whitespace glyphs cover no pixels, so any covered pixel means
+// the font would draw a missing glyph box instead.
+func (f *missingGlyphFace) missingSpace(r rune) bool {
+	if r == ' ' || !unicode.IsSpace(r) {
+		return false
+	}
+	dr, mask, maskp, _, ok := f.Face.Glyph(fixed.Point26_6{}, r)
+	if !ok {
+		return true
+	}
+	if mask == nil {
+		return false
+	}
+	offset := maskp.Sub(dr.Min)
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		for x := dr.Min.X; x < dr.Max.X; x++ {
+			if _, _, _, a := mask.At(x+offset.X, y+offset.Y).RGBA(); a > 0 {
+				return true
 			}
 		}
-	}()
+	}
+	return false
+}
 
-	pingBefore, pingAfter, pingQuit := xevent.MainPing(X)
-	for {
-		select {
-		case <-pingBefore:
-			<-pingAfter
-		case text := <-stdin:
-			bar.Draw(text)
+// spaceAdvance returns advance of a plain space.
+func (f *missingGlyphFace) spaceAdvance() fixed.Int26_6 {
+	advance, _ := f.Face.GlyphAdvance(' ')
+	return advance
+}
+
+// fontKey identifies cached font face.
+type fontKey struct {
+	def string
+	// height is the height face got fitted to, if any.
+	height int
+}
+
+// lookupFace returns font face set for the piece.
+// Fonts specified by name are looked up once and cached,
+// per row height with FitHeight, so that resized bars refit them.
+func (b *Bar) lookupFace(piece *TextPiece) font.Face {
+	if piece.FontName == "" {
+		return b.face(piece.Font)
+	}
+	key := fontKey{def: styledFontDef(piece.FontName, piece.Bold, piece.Italic)}
+	if b.FitHeight {
+		key.height = b.rowHeight()
+	}
+	if face, ok := b.fontCache[key]; ok {
+		return face
+	}
+	if b.fontCache == nil {
+		b.fontCache = map[fontKey]font.Face{}
+	}
+	face, _ := findFont(key.def)
+	if b.FitHeight {
+		face = fitFont(key.def, face, key.height)
+	}
+	b.fontCache[key] = face
+	return face
+}
+
+// rowHeight returns height of the lowest text row of all the screens,
+// or 0 if there are no screens.
+func (b *Bar) rowHeight() int {
+	height := 0
+	for i, geometry := range b.Geometries {
+		row := (int(geometry.Height) - 2*b.Border) / b.rows()
+		if i == 0 || row < height {
+			height = row
+		}
+	}
+	return height
+}
+
+// warnTall logs, once per face, when glyphs drawn with face
+// are higher than bar rows, so get clipped.
+func (b *Bar) warnTall(piece *TextPiece, face font.Face) {
+	height := b.rowHeight()
+	if b.tallFaces[face] || height <= 0 || faceHeight(face) <= height {
+		return
+	}
+	if b.tallFaces == nil {
+		b.tallFaces = map[font.Face]bool{}
+	}
+	b.tallFaces[face] = true
+	logf(WARN, "Font of piece %s is %dpx high, more than %dpx of bar rows, clipping it", piece, faceHeight(face), height)
+}
+
+// clearFonts forgets fonts looked up by name, so that they are looked up
+// again on next use, picking up fonts installed in the meantime.
+func (b *Bar) clearFonts() {
+	b.fontCache = nil
+	b.wrappedFaces = nil
+	b.tallFaces = nil
+	if b.widths != nil {
+		b.widths.clear()
+	}
+	resetFontFinder()
+	logf(INFO, "Fonts changed, looking them up again")
+}
+
+// styledFontDef adds bold and/or italic style to font definition
+// in form of name[:size], unless its name already mentions them.
+func styledFontDef(def string, bold, italic bool) string {
+	name, size := def, ""
+	if i := strings.LastIndexByte(def, ':'); i != -1 {
+		name, size = def[:i], def[i:]
+	}
+	lower := strings.ToLower(name)
+	if bold && !strings.Contains(lower, "bold") {
+		name += " Bold"
+	}
+	if italic && !strings.Contains(lower, "italic") && !strings.Contains(lower, "oblique") {
+		name += " Italic"
+	}
+	return name + size
+}
+
+// rows returns number of text rows stacked within each bar.
+func (b *Bar) rows() int {
+	if b.Rows < 1 {
+		return 1
+	}
+	return b.Rows
+}
+
+// drawText draws text of the piece into row of given screen image,
+// starting at xs, with fg and bg colors. Returns x coordinate
+// where the text ends and whether it was drawn at all.
+func (b *Bar) drawText(
+	img *xgraphics.Image, piece *TextPiece, pFont font.Face,
+	fg, bg *xgraphics.BGRA, xs fixed.Int26_6, y, height int, text string,
+) (fixed.Int26_6, bool) {
+	if piece.Rotate != 0 {
+		return b.drawRotated(img, piece, pFont, fg, bg, xs, y, height, text)
+	}
+	// Pieces are measured before it is known where they start,
+	// so their tab stops count from the piece start.
+	segments, width := tabSegments(pFont, text, fixed.I(b.TabWidth))
+	if fakeBold(piece) && text != "" {
+		width += fixed.I(1)
+	}
+	advance := pieceAdvance(piece, width)
+
+	// Cursors keep the sub-pixel precision and get rounded only here,
+	// the same way on both sides, so that adjacent pieces neither
+	// overlap nor leave gaps, however many of them there are.
+	// Text itself is still drawn at the exact position.
+	subimg := img.SubImage(image.Rect(
+		xs.Round(), y, (xs + advance).Round(), y+height,
+	))
+	if subimg == nil {
+		logEvery(hotLogInterval, WARN,
+			"Cannot create Subimage for coords `%dx%dx%dx%d`\n",
+			xs, y, xs+advance, y+height,
+		)
+		return xs, false
+	}
+	subximg := subimg.(*xgraphics.Image)
+
+	// Without background, whatever is already drawn beneath stays visible.
+	if bg != nil {
+		block, ok := subximg, true
+		if piece.BlockHeight > 0 && piece.BlockHeight < height {
+			top := y + (height-piece.BlockHeight)/2
+			block, ok = subximg.SubImage(image.Rect(
+				subximg.Rect.Min.X, top, subximg.Rect.Max.X, top+piece.BlockHeight,
+			)).(*xgraphics.Image)
+		}
+		if ok && piece.BoxBorder > 0 {
+			drawBorder(block, piece.BoxBorder, bg)
+		} else if ok {
+			block.For(func(x, y int) xgraphics.BGRA { return *bg })
+		}
+	} else if piece.Urgent && b.UrgentColor != nil {
+		block := subximg.Rect
+		if piece.BlockHeight > 0 && piece.BlockHeight < height {
+			top := y + (height-piece.BlockHeight)/2
+			block = image.Rect(block.Min.X, top, block.Max.X, top+piece.BlockHeight)
+		}
+		pulseRect(img, block, b.UrgentColor, b.urgentLevel)
+	}
+
+	// Right aligned pieces are padded on the left,
+	// by LeadPad and whatever MinWidth adds to it.
+	textX := xs + fixed.I(piece.LeadPad)
+	if piece.Align == RIGHT {
+		textX = xs + advance - width - fixed.I(piece.GraphWidth)
+	}
+	// Graph is skipped when fully transparent, just like text below.
+	if piece.Graph != nil && fg.A != 0 {
+		graphHeight := piece.GraphHeight
+		if graphHeight == 0 || graphHeight > height {
+			graphHeight = height
+		}
+		top := y + (height-graphHeight)/2
+		drawGraph(subximg, image.Rect(
+			textX.Round(), top, textX.Round()+piece.GraphWidth, top+graphHeight,
+		), piece.Graph, fg)
+	}
+	textX += fixed.I(piece.GraphWidth)
+	pt := fixed.Point26_6{X: textX, Y: textY(pFont, b.VAlign, y, height) + fixed.I(b.baseline(piece))}
+	outline := piece.Outline
+	if outline == nil {
+		outline = b.TextOutline
+	}
+	if outline != nil {
+		for _, offset := range outlineOffsets {
+			for _, segment := range segments {
+				segmentPt := pt.Add(fixed.Point26_6{X: segment.x}).Add(offset)
+				subximg.Text(segmentPt, outline, pFont, segment.text)
+				if fakeBold(piece) {
+					subximg.Text(segmentPt.Add(fixed.P(1, 0)), outline, pFont, segment.text)
+				}
+			}
+		}
+	}
+	// Fully transparent text is not drawn at all, while still
+	// taking its space, e.g. for spacers. Its outline still is.
+	if fg.A == 0 {
+		return xs + advance, true
+	}
+	for _, segment := range segments {
+		segmentPt := pt.Add(fixed.Point26_6{X: segment.x})
+		subximg.Text(segmentPt, fg, pFont, segment.text)
+		if fakeBold(piece) {
+			subximg.Text(segmentPt.Add(fixed.P(1, 0)), fg, pFont, segment.text)
+		}
+	}
+	if piece.Underline {
+		metrics := pFont.Metrics()
+		baseline := (pt.Y + metrics.Height - fixed.I(metrics.CaretSlope.Y*2)).Round()
+		for x := textX.Round(); x < (textX + width).Round(); x++ {
+			subximg.SetBGRA(x, baseline+1, *fg)
+		}
+	}
+
+	return xs + advance, true
+}
+
+// sideways tells whether text of the piece runs vertically.
+func sideways(piece *TextPiece) bool {
+	return piece.Rotate == 90 || piece.Rotate == 270
+}
+
+// drawRotated draws rotated piece the same way drawText does.
+// Piece is drawn upright into a temporary image first, over what
+// is beneath it, which then gets copied back rotated. Sideways text
+// is centered vertically within the row, and clipped to it.
+func (b *Bar) drawRotated(
+	img *xgraphics.Image, piece *TextPiece, pFont font.Face,
+	fg, bg *xgraphics.BGRA, xs fixed.Int26_6, y, height int, text string,
+) (fixed.Int26_6, bool) {
+	upright := *piece
+	upright.Rotate = 0
+	upright.Align = LEFT
+	upright.BlockHeight = 0
+	// Pulsing is done here, over the whole area.
+	upright.Urgent = false
+	length := pieceAdvance(&upright, b.textWidth(&upright, pFont, text)).Ceil()
+	upright.MinWidth = 0
+
+	var advance fixed.Int26_6
+	var size image.Point
+	if sideways(piece) {
+		advance = pieceAdvance(piece, fixed.I(faceHeight(pFont)))
+		size = image.Pt(length, faceHeight(pFont))
+	} else {
+		advance = fixed.I(length)
+		size = image.Pt(length, height)
+	}
+	area := image.Rect(xs.Round(), y, (xs + advance).Round(), y+height).Intersect(img.Bounds())
+	if area.Empty() {
+		return xs, false
+	}
+	if piece.BoxBorder > 0 && bg != nil {
+		if block, ok := img.SubImage(area).(*xgraphics.Image); ok && block != nil {
+			drawBorder(block, piece.BoxBorder, bg)
+		}
+	} else if bg == nil && piece.Urgent && b.UrgentColor != nil {
+		pulseRect(img, area, b.UrgentColor, b.urgentLevel)
+	} else {
+		fillRect(img, area, bg)
+	}
+
+	// Top left corner of the rotated text, within the bar.
+	origin := image.Pt(xs.Round(), y)
+	if sideways(piece) {
+		origin.Y += (height - length) / 2
+	}
+	at := func(p image.Point) image.Point {
+		switch piece.Rotate {
+		case 90:
+			return origin.Add(image.Pt(size.Y-1-p.Y, p.X))
+		case 270:
+			return origin.Add(image.Pt(p.Y, size.X-1-p.X))
+		default:
+			return origin.Add(image.Pt(size.X-1-p.X, size.Y-1-p.Y))
+		}
+	}
+
+	tmp := xgraphics.New(b.X, image.Rectangle{Max: size})
+	for tx := 0; tx < size.X; tx++ {
+		for ty := 0; ty < size.Y; ty++ {
+			if p := at(image.Pt(tx, ty)); p.In(area) {
+				tmp.SetBGRA(tx, ty, img.At(p.X, p.Y).(xgraphics.BGRA))
+			}
+		}
+	}
+	b.drawText(tmp, &upright, pFont, fg, nil, 0, 0, size.Y, text)
+	for tx := 0; tx < size.X; tx++ {
+		for ty := 0; ty < size.Y; ty++ {
+			if p := at(image.Pt(tx, ty)); p.In(area) {
+				img.SetBGRA(p.X, p.Y, tmp.At(tx, ty).(xgraphics.BGRA))
+			}
+		}
+	}
+	return xs + advance, true
+}
+
+// outlineOffsets are directions text is drawn at in outline color,
+// to make a halo around it.
+var outlineOffsets = []fixed.Point26_6{
+	fixed.P(-1, -1), fixed.P(0, -1), fixed.P(1, -1),
+	fixed.P(-1, 0), fixed.P(1, 0),
+	fixed.P(-1, 1), fixed.P(0, 1), fixed.P(1, 1),
+}
+
+// aliasedFace draws glyphs without antialiasing, by making every pixel
+// of their masks either fully covered or not covered at all.
+type aliasedFace struct {
+	font.Face
+}
+
+func (f *aliasedFace) Glyph(
+	dot fixed.Point26_6, r rune,
+) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	dr, mask, maskp, advance, ok := f.Face.Glyph(dot, r)
+	if mask != nil {
+		mask = thresholdMask{mask}
+	}
+	return dr, mask, maskp, advance, ok
+}
+
+// thresholdMask turns coverage of at least a half into full coverage
+// and anything less into no coverage.
+type thresholdMask struct {
+	image.Image
+}
+
+func (m thresholdMask) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (m thresholdMask) At(x, y int) color.Color {
+	if _, _, _, a := m.Image.At(x, y).RGBA(); a >= 0x8000 {
+		return color.Opaque
+	}
+	return color.Transparent
+}
+
+// tabSegment is a part of text between tabs, starting at x.
+type tabSegment struct {
+	text string
+	x    fixed.Int26_6
+}
+
+// tabSegments splits text on tabs into segments, each starting at
+// the next multiple of tabWidth from the text start. Returns segments,
+// with x relative to the text start, and the total text width.
+// Zero tabWidth leaves tabs as they are.
+func tabSegments(face font.Face, text string, tabWidth fixed.Int26_6) ([]tabSegment, fixed.Int26_6) {
+	if tabWidth <= 0 || !strings.ContainsRune(text, '\t') {
+		return []tabSegment{{text, 0}}, font.MeasureString(face, text)
+	}
+	var segments []tabSegment
+	var x fixed.Int26_6
+	for i, part := range strings.Split(text, "\t") {
+		if i > 0 {
+			x = (x/tabWidth + 1) * tabWidth
+		}
+		if part != "" {
+			segments = append(segments, tabSegment{part, x})
+		}
+		x += font.MeasureString(face, part)
+	}
+	return segments, x
+}
+
+// fakeBold checks whether piece should be emboldened by drawing it twice,
+// because there is no bold font to draw it with.
+func fakeBold(piece *TextPiece) bool {
+	return piece.Bold && piece.FontName == ""
+}
+
+// pieceWidth returns width of the whole piece text, like textWidth,
+// but remembers it, as the same texts get measured again and again.
+func (b *Bar) pieceWidth(piece *TextPiece, face font.Face) fixed.Int26_6 {
+	if sideways(piece) {
+		return fixed.I(faceHeight(face))
+	}
+	if b.widths == nil {
+		b.widths = newWidthCache(widthCacheSize)
+	}
+	key := widthKey{face, piece.Text, fakeBold(piece)}
+	return b.widths.get(key, func() fixed.Int26_6 {
+		return b.textWidth(piece, face, piece.Text)
+	})
+}
+
+// textWidth returns width of the text drawn as a part of the piece,
+// with tab stops counted from the piece start.
+func (b *Bar) textWidth(piece *TextPiece, face font.Face, text string) fixed.Int26_6 {
+	_, width := tabSegments(face, text, fixed.I(b.TabWidth))
+	if fakeBold(piece) && text != "" {
+		width += fixed.I(1)
+	}
+	return width
+}
+
+// pieceAdvance returns how far drawing piece of given text width
+// moves the cursor, taking the piece padding and minimum width into account.
+func pieceAdvance(piece *TextPiece, width fixed.Int26_6) fixed.Int26_6 {
+	// Padding and graph of sideways pieces go along their text instead.
+	if !sideways(piece) {
+		width += fixed.I(piece.LeadPad + piece.GraphWidth)
+	}
+	if minWidth := fixed.I(piece.MinWidth); width < minWidth {
+		return minWidth
+	}
+	return width
+}
+
+// fillRect fills rect of img with color, if there is any color.
+func fillRect(img *xgraphics.Image, rect image.Rectangle, color *xgraphics.BGRA) {
+	if color == nil || rect.Empty() {
+		return
+	}
+	subimg, ok := img.SubImage(rect).(*xgraphics.Image)
+	if !ok || subimg == nil {
+		return
+	}
+	subimg.For(func(x, y int) xgraphics.BGRA { return *color })
+}
+
+// drawGraph plots values as columns of color within rect, each one
+// as high as its value, relative to the biggest one. When there are
+// more values than pixels, only the last ones are plotted.
+func drawGraph(img *xgraphics.Image, rect image.Rectangle, values []float64, color *xgraphics.BGRA) {
+	width := rect.Dx()
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	highest := 0.0
+	for _, value := range values {
+		highest = math.Max(highest, value)
+	}
+	if highest == 0 {
+		return
+	}
+	for i, value := range values {
+		height := int(math.Round(value / highest * float64(rect.Dy())))
+		fillRect(img, image.Rect(
+			rect.Min.X+i*width/len(values), rect.Max.Y-height,
+			rect.Min.X+(i+1)*width/len(values), rect.Max.Y,
+		), color)
+	}
+}
+
+// drawBorder draws a border of given width along the edges of img.
+func drawBorder(img *xgraphics.Image, width int, color *xgraphics.BGRA) {
+	r := img.Bounds()
+	inner := r.Inset(width)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if !image.Pt(x, y).In(inner) {
+				img.SetBGRA(x, y, *color)
+			}
+		}
+	}
+}
+
+// pieceScreens returns indices of screens, out of n available,
+// that piece should be drawn on.
+func pieceScreens(piece *TextPiece, n int) []uint {
+	screens := []uint{}
+	if piece.Screens == nil {
+		for i := 0; i < n; i++ {
+			if !contains(piece.NotScreens, uint(i)) {
+				screens = append(screens, uint(i))
+			}
+		}
+	} else {
+		for _, screen := range piece.Screens {
+			if int(screen) < n && !contains(piece.NotScreens, screen) {
+				screens = append(screens, screen)
+			}
+		}
+	}
+	return screens
+}
+
+// spinFrames are frames of spinners without text of their own.
+const spinFrames = `|/-\`
+
+// spinners replaces text of spinner pieces with their current frame.
+// Pieces are copied, rather than changed in place.
+func (b *Bar) spinners(text []*TextPiece) []*TextPiece {
+	var spun []*TextPiece
+	for i, piece := range text {
+		if !piece.Spin {
+			continue
+		}
+		if spun == nil {
+			spun = append([]*TextPiece{}, text...)
+		}
+		frames := []rune(piece.Text)
+		if len(frames) == 0 {
+			frames = []rune(spinFrames)
+		}
+		spinner := *piece
+		spinner.Text = string(frames[b.spinFrame%len(frames)])
+		spun[i] = &spinner
+	}
+	if spun == nil {
+		return text
+	}
+	return spun
+}
+
+// animate moves spinners to their next frame and redraws the bar,
+// if there are any spinners or urgent pieces drawn.
+func (b *Bar) animate() {
+	spin, urgent := false, false
+	for _, piece := range b.lastText {
+		spin = spin || piece.Spin
+		urgent = urgent || (piece.Urgent && b.UrgentColor != nil)
+	}
+	if spin {
+		b.spinFrame++
+	}
+	if spin || urgent {
+		b.Draw(b.lastText)
+	}
+}
+
+// columns makes pieces of every column at least as wide as the widest
+// of them, so that columns line up across rows and screens.
+// Pieces are copied, rather than changed in place.
+func (b *Bar) columns(text []*TextPiece) []*TextPiece {
+	widths := map[int]fixed.Int26_6{}
+	for _, piece := range text {
+		if piece.Column == nil {
+			continue
+		}
+		advance := pieceAdvance(piece, b.pieceWidth(piece, b.pieceFace(piece)))
+		if advance > widths[*piece.Column] {
+			widths[*piece.Column] = advance
+		}
+	}
+	if len(widths) == 0 {
+		return text
+	}
+	columns := make([]*TextPiece, len(text))
+	for i, piece := range text {
+		columns[i] = piece
+		if piece.Column != nil {
+			column := *piece
+			column.MinWidth = widths[*piece.Column].Ceil()
+			columns[i] = &column
+		}
+	}
+	return columns
+}
+
+// Measure returns total width of TextPieces on each of n screens.
+func (b *Bar) Measure(text []*TextPiece, n int) []fixed.Int26_6 {
+	text = b.columns(b.spinners(text))
+	widths := make([]fixed.Int26_6, n)
+	for _, piece := range text {
+		advance := pieceAdvance(piece, b.pieceWidth(piece, b.pieceFace(piece))) + fixed.I(piece.Gap)
+		for _, screen := range pieceScreens(piece, n) {
+			widths[screen] += advance
+		}
+	}
+	return widths
+}
+
+// drawPiece stores TextPiece along with everything needed to draw it,
+// resolved up front, so that screens can be composed concurrently.
+type drawPiece struct {
+	*TextPiece
+	face    font.Face
+	advance fixed.Int26_6
+	screens []uint
+}
+
+// lockedFace guards font.Face, which is not safe for concurrent use,
+// so that it can be shared by screens being composed concurrently.
+// Glyph masks get copied while locked, as faces reuse their buffers.
+// Faces it wraps, e.g. missingGlyphFace reading masks of the glyphs
+// it checks, are then only ever used while locked as well.
+type lockedFace struct {
+	mu   sync.Mutex
+	face font.Face
+}
+
+func (f *lockedFace) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Close()
+}
+
+func (f *lockedFace) Glyph(dot fixed.Point26_6, r rune) (
+	image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool,
+) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dr, mask, maskp, advance, ok := f.face.Glyph(dot, r)
+	if mask == nil || dr.Empty() {
+		return dr, mask, maskp, advance, ok
+	}
+	copied := image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	draw.Draw(copied, copied.Bounds(), mask, maskp, draw.Src)
+	return dr, copied, image.Point{}, advance, ok
+}
+
+func (f *lockedFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.GlyphBounds(r)
+}
+
+func (f *lockedFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.GlyphAdvance(r)
+}
+
+func (f *lockedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Kern(r0, r1)
+}
+
+func (f *lockedFace) Metrics() font.Metrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Metrics()
+}
+
+// resolve prepares TextPieces to be drawn on n screens.
+func (b *Bar) resolve(text []*TextPiece, n int) []*drawPiece {
+	text = b.columns(b.spinners(text))
+	faces := map[font.Face]*lockedFace{}
+	pieces := make([]*drawPiece, len(text))
+	for i, piece := range text {
+		face := b.pieceFace(piece)
+		b.warnTall(piece, face)
+		if faces[face] == nil {
+			faces[face] = &lockedFace{face: face}
+		}
+		pieces[i] = &drawPiece{
+			TextPiece: piece,
+			face:      faces[face],
+			advance:   pieceAdvance(piece, b.pieceWidth(piece, face)),
+			screens:   pieceScreens(piece, n),
+		}
+	}
+	return pieces
+}
+
+// absolutePiece is a piece with AbsX, together with row and colors
+// it is drawn with, waiting for the other pieces to be drawn first.
+type absolutePiece struct {
+	*drawPiece
+	row    uint
+	fg, bg *xgraphics.BGRA
+}
+
+// compose draws pieces onto the canvas of the given screen.
+// Does not talk to X, so that screens can be composed concurrently.
+func (b *Bar) compose(screen uint, pieces []*drawPiece) {
+	geometry := b.Geometries[screen]
+	c := b.canvases[screen]
+	img, xsl, xsr, xsc := c.img, c.xsl, c.xsr, c.xsc
+	c.spans, c.spanPieces = c.spans[:0], c.spanPieces[:0]
+	background := b.Background.at(screen)
+	if bg := b.canvases[screen].bg; bg != nil {
+		copy(img.Pix, bg.Pix)
+		// Default background is not drawn, so that the image shows through.
+		background = nil
+	} else {
+		img.For(func(x, y int) xgraphics.BGRA { return *background })
+	}
+	if b.Border > 0 {
+		drawBorder(img, b.Border, b.BorderColor)
+	}
+
+	rows := b.rows()
+	rowHeight := (int(geometry.Height) - 2*b.Border) / rows
+	for row := range xsr {
+		xsl[row] = fixed.I(b.Border)
+		xsr[row] = fixed.I(int(geometry.Width) - b.Border)
+		xsc[row] = 0
+	}
+	// Center aligned pieces of a row are centered together,
+	// so their width has to be known up front.
+	for _, piece := range pieces {
+		if piece.Align == CENTER && piece.AbsX == nil && contains(piece.screens, screen) && piece.Row < uint(rows) {
+			xsc[piece.Row] += piece.advance + fixed.I(piece.Gap)
+		}
+	}
+	for row := range xsc {
+		xsc[row] = (fixed.I(int(geometry.Width)) - xsc[row]) / 2
+	}
+	// Rows which outermost left and right pieces were drawn already.
+	var bledLeft, bledRight []bool
+	if b.EdgeBleed {
+		bledLeft, bledRight = make([]bool, rows), make([]bool, rows)
+	}
+	// Pieces with AbsX are drawn over all the others, ordered by ZIndex.
+	var absolute []absolutePiece
+	var shift uint
+	for _, piece := range pieces {
+		if !contains(piece.screens, screen) {
+			continue
+		}
+		row := piece.Row + shift
+		if row >= uint(rows) {
+			continue
+		}
+		fg, bg := piece.Foreground, piece.Background
+		if fg == nil {
+			fg = b.Foreground.at(screen)
+		}
+		if bg == nil {
+			bg = background
+		}
+		// Without background, urgent pieces pulse over
+		// the background image instead, once drawn.
+		if piece.Urgent && b.UrgentColor != nil && bg != nil {
+			bg = blendLevel(bg, b.UrgentColor, b.urgentLevel)
+		}
+
+		if piece.AbsX != nil {
+			absolute = append(absolute, absolutePiece{piece, row, fg, bg})
+			continue
+		}
+
+		if piece.Gap != 0 {
+			if piece.Align == RIGHT {
+				xsr[row] -= fixed.I(piece.Gap)
+			} else if piece.Align == CENTER {
+				xsc[row] += fixed.I(piece.Gap)
+			} else {
+				xsl[row] += fixed.I(piece.Gap)
+			}
+			if piece.Text == "" {
+				continue
+			}
+		}
+
+		if piece.Align == RIGHT {
+			xs := xsr[row] - piece.advance
+			y := b.Border + int(row)*rowHeight
+			if _, ok := b.drawText(img, piece.TextPiece, piece.face, fg, bg, xs, y, rowHeight, piece.Text); ok {
+				if bledRight != nil && !bledRight[row] {
+					bledRight[row] = true
+					fillRect(img, image.Rect(xsr[row].Round(), y, int(geometry.Width), y+rowHeight), bg)
+				}
+				c.addSpan(image.Rect(xs.Round(), y, xsr[row].Round(), y+rowHeight), piece.TextPiece)
+				xsr[row] = xs
+			}
+			continue
+		}
+
+		if piece.Align == CENTER {
+			xs := xsc[row]
+			y := b.Border + int(row)*rowHeight
+			if _, ok := b.drawText(img, piece.TextPiece, piece.face, fg, bg, xs, y, rowHeight, piece.Text); ok {
+				c.addSpan(image.Rect(xs.Round(), y, (xs+piece.advance).Round(), y+rowHeight), piece.TextPiece)
+				xsc[row] = xs + piece.advance
+			}
+			continue
+		}
+
+		lines := []string{piece.Text}
+		// Graphs and rotated text are drawn whole, along with their text.
+		if b.Wrap && piece.Graph == nil && piece.Rotate == 0 {
+			lines = wrapText(
+				piece.face, piece.Text, xsr[row]-xsl[row],
+				fixed.I(int(geometry.Width)-2*b.Border),
+			)
+		}
+		for j, line := range lines {
+			if j > 0 {
+				shift++
+				row++
+				if row >= uint(rows) {
+					break
+				}
+			}
+			if line == "" && piece.Graph == nil {
+				continue
+			}
+			xs := xsl[row]
+			y := b.Border + int(row)*rowHeight
+			if xsNew, ok := b.drawText(img, piece.TextPiece, piece.face, fg, bg, xs, y, rowHeight, line); ok {
+				if bledLeft != nil && !bledLeft[row] {
+					bledLeft[row] = true
+					fillRect(img, image.Rect(0, y, xs.Round(), y+rowHeight), bg)
+				}
+				c.addSpan(image.Rect(xs.Round(), y, xsNew.Round(), y+rowHeight), piece.TextPiece)
+				xsl[row] = xsNew
+			}
+		}
+	}
+	sort.SliceStable(absolute, func(i, j int) bool {
+		return absolute[i].ZIndex < absolute[j].ZIndex
+	})
+	for _, piece := range absolute {
+		xs := fixed.I(*piece.AbsX)
+		if piece.AbsXPercent {
+			xs = fixed.I(int(geometry.Width)) * fixed.Int26_6(*piece.AbsX) / 100
+		}
+		if xs < 0 {
+			xs += fixed.I(int(geometry.Width))
+		}
+		if piece.Align == CENTER {
+			xs -= piece.advance / 2
+		}
+		y := b.Border + int(piece.row)*rowHeight
+		if xsNew, ok := b.drawText(img, piece.TextPiece, piece.face, piece.fg, piece.bg, xs, y, rowHeight, piece.Text); ok {
+			c.addSpan(image.Rect(xs.Round(), y, xsNew.Round(), y+rowHeight), piece.TextPiece)
+		}
+	}
+	// Drawn over the pieces, so that their backgrounds do not break it.
+	fillRect(img, b.accentRect(img.Bounds()), b.AccentLine.Color)
+	b.highlightHovered(screen)
+}
+
+// accentRect returns area of AccentLine within bar bounds,
+// along the edge facing away from the screen edge.
+func (b *Bar) accentRect(bounds image.Rectangle) image.Rectangle {
+	if b.position == BOTTOM {
+		return image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+b.AccentLine.Width)
+	}
+	return image.Rect(bounds.Min.X, bounds.Max.Y-b.AccentLine.Width, bounds.Max.X, bounds.Max.Y)
+}
+
+// mirrorOf returns index of a screen before the given one that pieces
+// get composed the same on, or -1 if there is none. Screens with
+// pointer over them are never mirrored, as they might be highlighted.
+func (b *Bar) mirrorOf(screen uint, pieces []*drawPiece) int {
+	geometry := b.Geometries[screen]
+	if b.canvases[screen].hovering {
+		return -1
+	}
+	for i := uint(0); i < screen; i++ {
+		other := b.Geometries[i]
+		if other.Width != geometry.Width || other.Height != geometry.Height || b.canvases[i].hovering {
+			continue
+		}
+		if *b.Foreground.at(i) != *b.Foreground.at(screen) || *b.Background.at(i) != *b.Background.at(screen) {
+			continue
+		}
+		same := true
+		for _, piece := range pieces {
+			if contains(piece.screens, i) != contains(piece.screens, screen) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return int(i)
+		}
+	}
+	return -1
+}
+
+// mirror makes canvas look the same as src, composed already.
+func (c *canvas) mirror(src *canvas) {
+	copy(c.img.Pix, src.img.Pix)
+	c.spans = append(c.spans[:0], src.spans...)
+	c.spanPieces = append(c.spanPieces[:0], src.spanPieces...)
+}
+
+// Draw draws TextPieces into X monitors.
+// Screens are composed concurrently, then sent to X one by one.
+// Every frame is first uploaded to the canvas pixmap as a whole,
+// which then gets copied to the window in a single request,
+// so that half drawn frames are never shown.
+func (b *Bar) Draw(text []*TextPiece) {
+	start := time.Now()
+	pieces := b.resolve(text, len(b.canvases))
+	b.lastText, b.lastPieces = text, pieces
+	b.urgentLevel = pulseLevel(timeNow(), b.UrgentPeriod)
+
+	// Screens looking the same are composed only once.
+	mirrors := make([]int, len(b.canvases))
+	var wg sync.WaitGroup
+	for i := range b.canvases {
+		mirrors[i] = b.mirrorOf(uint(i), pieces)
+		if mirrors[i] != -1 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b.compose(uint(i), pieces)
+		}(i)
+	}
+	wg.Wait()
+	for i, src := range mirrors {
+		if src != -1 {
+			b.canvases[i].mirror(b.canvases[src])
+		}
+	}
+
+	for i := range b.canvases {
+		b.show(i)
+	}
+	stats.drawn(time.Since(start))
+}
+
+// show sends composed canvas of the screen to its window
+// and maps the window, unless it is hidden or not ours.
+func (b *Bar) show(screen int) {
+	b.drawer.paint(b.canvases[screen].img, b.Windows[screen].Id)
+
+	if !b.hiddenWindows[screen] && b.Window == 0 {
+		b.drawer.mapWindow(b.Windows[screen].Id)
+	}
+}
+
+// readRecord reads from r until delim, returning the record
+// together with delim.
+func readRecord(r *bufio.Reader, delim string) (string, error) {
+	var record strings.Builder
+	last := delim[len(delim)-1]
+	for {
+		part, err := r.ReadString(last)
+		record.WriteString(part)
+		if err != nil || strings.HasSuffix(record.String(), delim) {
+			return record.String(), err
+		}
+	}
+}
+
+// readInput reads records ending with delim from r and sends text
+// scanned from every one of them to texts, until reading fails.
+// With more than one row, every text holds recent rows records.
+// With delimiter other than newline, every record is a text of its own,
+// with newlines in it starting new rows, or kept as spaces with one row.
+func readInput(r *bufio.Reader, delim string, rows int, parser Parser, texts chan<- []*TextPiece) error {
+	lines := []string{}
+	for {
+		str, err := readRecord(r, delim)
+		if err != nil {
+			return err
+		}
+		stats.linesParsed.Add(1)
+		if delim != "\n" {
+			str = strings.TrimSuffix(strings.TrimSuffix(str, delim), "\n")
+			if rows <= 1 {
+				// There is no next row for the rest of the record to go to.
+				str = strings.ReplaceAll(str, "\n", " ")
+			}
+			texts <- parser.Scan(strings.NewReader(str))
+		} else if rows > 1 {
+			lines = append(lines, strings.TrimSuffix(str, "\n"))
+			if len(lines) > rows {
+				lines = lines[len(lines)-rows:]
+			}
+			texts <- parser.Scan(strings.NewReader(strings.Join(lines, "\n")))
+		} else {
+			texts <- parser.Scan(strings.NewReader(str))
+		}
+	}
+}
+
+// readUntilEOF calls read again until it reaches the end of input,
+// logging any other errors it fails with, and closes texts afterwards.
+func readUntilEOF(read func() error, texts chan<- []*TextPiece) {
+	defer close(texts)
+	for {
+		err := read()
+		if err == nil || errors.Is(err, io.EOF) {
+			return
+		}
+		logEvery(hotLogInterval, ERROR, "Error reading stdin. Got `%s`", err)
+	}
+}
+
+// stdinBacklog is a number of input records waiting to be drawn,
+// before reading more of them blocks.
+const stdinBacklog = 16
+
+// latest returns the most recent of text and texts already waiting
+// in texts, dropping all the others.
+func latest(texts <-chan []*TextPiece, text []*TextPiece) []*TextPiece {
+	for {
+		select {
+		case newer, ok := <-texts:
+			if !ok {
+				return text
+			}
+			text = newer
+			stats.framesDropped.Add(1)
+		default:
+			return text
+		}
+	}
+}
+
+// redraw draws the most recently drawn text again, if there is any.
+// Otherwise windows stay unmapped until the first text arrives,
+// same as on startup.
+func (b *Bar) redraw() {
+	if b.lastText != nil {
+		b.Draw(b.lastText)
+	}
+}
+
+// requestRedraw asks the main loop to redraw, without blocking.
+// Requests made before the main loop gets to them end up as one redraw.
+func (b *Bar) requestRedraw() {
+	select {
+	case b.redraws <- struct{}{}:
+	default:
+	}
+}
+
+type fonts []font.Face
+
+// fontDefs stores font definitions as given in flags. They are resolved
+// with resolveFonts only after all the flags are parsed, so that flags
+// affecting fonts apply regardless of their order.
+type fontDefs []string
+
+func (f *fontDefs) String() string {
+	return fmt.Sprintf("%q", strings.Join(*f, ","))
+}
+
+func (f *fontDefs) Set(value string) error {
+	*f = append(*f, strings.Split(value, ",")...)
+	return nil
+}
+
+// resolveFonts returns faces for font definitions, along with descriptions
+// of fonts they resolved to, in the same order. Falls back to a default
+// font if there are no definitions. If strict is set, fails if any of
+// the fonts cannot be found, instead of silently using the closest
+// available ones.
+func resolveFonts(defs fontDefs, strict bool) (fonts, []fontInfo, error) {
+	var faces fonts
+	var infos []fontInfo
+	for _, def := range defs {
+		face, info, err := resolveFont(def)
+		if err != nil && strict {
+			return nil, nil, err
+		}
+		faces = append(faces, face)
+		infos = append(infos, info)
+	}
+	if len(faces) < 1 {
+		face, info, _ := resolveFontFallback("", 12, fontOptions{})
+		faces = append(faces, face)
+		infos = append(infos, info)
+	}
+	return faces, infos, nil
+}
+
+// colors stores per screen colors.
+type colors []*xgraphics.BGRA
+
+// at returns color for the given screen.
+// If there are less colors than screens, last color is used.
+func (c colors) at(screen uint) *xgraphics.BGRA {
+	if int(screen) >= len(c) {
+		return c[len(c)-1]
+	}
+	return c[screen]
+}
+
+func (c *colors) String() string {
+	str := make([]string, len(*c))
+	for i, color := range *c {
+		str[i] = formatBGRA(color)
+	}
+	return strings.Join(str, ",")
+}
+
+func (c *colors) Set(value string) error {
+	var parsed colors
+	for _, color := range strings.Split(value, ",") {
+		bgra, err := strconv.ParseUint(color, 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid color `%s`", color)
+		}
+		parsed = append(parsed, NewBGRA(bgra))
+	}
+	*c = parsed
+	return nil
+}
+
+// AccentLine is a line of given color and width in pixels.
+type AccentLine struct {
+	Color *xgraphics.BGRA
+	Width int
+}
+
+func (a *AccentLine) String() string {
+	if a.Color == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", formatBGRA(a.Color), a.Width)
+}
+
+func (a *AccentLine) Set(value string) error {
+	color, width, ok := strings.Cut(value, ":")
+	bgra, err := strconv.ParseUint(color, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid color `%s`", color)
+	}
+	px := 1
+	if ok {
+		px, err = strconv.Atoi(width)
+		if err != nil || px < 1 {
+			return fmt.Errorf("invalid accent line width `%s`", width)
+		}
+	}
+	*a = AccentLine{NewBGRA(bgra), px}
+	return nil
+}
+
+// defaultHeight is height of bars without geometries and of geometries
+// Geometries.Set cannot parse. It is set by flags, all parsed before
+// geometries are, see geometryDefs.
+var defaultHeight uint16 = 16
+
+type Geometries []*Geometry
+
+func (g *Geometries) String() string {
+	str := make([]string, len(*g))
+	for i, g := range *g {
+		str[i] = g.String()
+	}
+	j := strings.Join(str, ",")
+	if j == "" {
+		j = (&Geometry{Height: defaultHeight}).String()
+	}
+	return fmt.Sprintf("%q", j)
+}
+
+func (g *Geometries) Set(value string) error {
+	if len(*g) > 0 {
+		return fmt.Errorf("geometries flag already set")
+	}
+	if value == "" {
+		return nil
+	}
+	for _, geometry := range strings.Split(value, ",") {
+		if geometry == "" {
+			*g = append(*g, nil)
+		} else {
+			geom := &Geometry{}
+			// Y takes its sign, if any, as an offset from the opposite edge.
+			_, err := fmt.Sscanf(
+				geometry, "%dx%d+%d%d",
+				&geom.Width, &geom.Height, &geom.X, &geom.Y,
+			)
+			if err != nil {
+				geom = &Geometry{Height: defaultHeight}
+				logf(WARN, "Bad geometry `%s`, using default", geometry)
+			}
+			*g = append(*g, geom)
+		}
+	}
+	return nil
+}
+
+// geometryDefs stores geometries as given in flags. They are parsed with
+// Geometries.Set only after all the flags are parsed, so that
+// defaultHeight applies to them regardless of flag order.
+type geometryDefs struct {
+	value string
+	set   bool
+}
+
+func (d *geometryDefs) String() string {
+	if !d.set {
+		return (&Geometries{}).String()
+	}
+	return fmt.Sprintf("%q", d.value)
+}
+
+func (d *geometryDefs) Set(value string) error {
+	if d.set {
+		return fmt.Errorf("geometries flag already set")
+	}
+	d.value, d.set = value, true
+	return nil
+}
+
+// measure reads records ending with delim from r and writes width of each of them to w,
+// as `screen\twidth` lines, one per every screen referenced in the record.
+// Nothing is drawn, so no X connection is necessary.
+func measure(r io.Reader, w io.Writer, delim string, parser Parser, fonts fonts, opts Options) error {
+	bar := &Bar{Options: opts, Fonts: fonts}
+	reader := bufio.NewReader(r)
+
+	for {
+		str, err := readRecord(reader, delim)
+		if delim != "\n" {
+			str = strings.TrimSuffix(str, delim)
+		}
+		if str != "" {
+			text := parser.Scan(strings.NewReader(str))
+
+			n := 1
+			for _, piece := range text {
+				for _, screen := range append(piece.Screens, piece.NotScreens...) {
+					if int(screen) >= n {
+						n = int(screen) + 1
+					}
+				}
+			}
+			for screen, width := range bar.Measure(text, n) {
+				fmt.Fprintf(w, "%d\t%d\n", screen, width.Ceil())
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// dump reads records ending with delim from r and writes pieces
+// each of them is scanned into to w, one per line, followed by
+// problems found in it, if parser reports them, and an empty line.
+// Nothing is drawn, so no X connection is necessary.
+func dump(r io.Reader, w io.Writer, delim string, parser Parser) error {
+	reader := bufio.NewReader(r)
+
+	for {
+		str, err := readRecord(reader, delim)
+		if delim != "\n" {
+			str = strings.TrimSuffix(str, delim)
+		}
+		if str != "" {
+			pieces, errs := scanWithErrors(parser, strings.NewReader(str))
+			for _, piece := range pieces {
+				fmt.Fprintln(w, piece)
+			}
+			for _, err := range errs {
+				fmt.Fprintf(w, "error: %s\n", err)
+			}
+			fmt.Fprintln(w)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// main gets command line arguments, creates X connection and initializes Bar.
+// This is also where X event loop and Stdin reading lies.
+func main() {
+	bottom := flag.Bool("bottom", false, "Place bar at the bottom of the screen")
+	fgColors := colors{NewBGRA(0xFFFFFFFF)}
+	flag.Var(&fgColors, "fg", "Comma separated list of per monitor foreground colors (0xAARRGGBB)")
+	bgColors := colors{NewBGRA(0xFF000000)}
+	flag.Var(&bgColors, "bg", "Comma separated list of per monitor background colors (0xAARRGGBB)")
+	var fontDefs fontDefs
+	themeName := flag.String("theme", "", "Built-in theme (dark or light), or name of theme file in $XDG_CONFIG_HOME/gobar/themes, setting defaults of other flags")
+	flag.Var(&fontDefs, "fonts", "Comma separated list of fonts in form of path[:size[:bold][:italic]]")
+	strictFonts := flag.Bool("strict-fonts", false, "Fail if any of the fonts cannot be found")
+	flag.BoolVar(&noAntialias, "no-antialias", false, "Draw text without antialiasing")
+	listFontsFlag := flag.Bool("list-fonts", false, "Print files the fonts resolved to and exit")
+	flag.Func("default-height", "Height of bars without -geometries and of geometries that cannot be parsed, in pixels (default 16)", func(value string) error {
+		height, err := strconv.ParseUint(value, 10, 16)
+		if err != nil || height == 0 {
+			return fmt.Errorf("invalid height `%s`", value)
+		}
+		defaultHeight = uint16(height)
+		return nil
+	})
+	var geometryDefs geometryDefs
+	flag.Var(&geometryDefs, "geometries", "Comma separated list of monitor geometries (<w>x<h>+<x>+<y>), for <w> and <h>, 0 means 100%")
+	rows := flag.Int("rows", 1, "Number of recent input lines stacked within the bar")
+	wrap := flag.Bool("wrap", false, "Wrap overflowing left aligned text into the next row")
+	var valign VAlign
+	flag.Var(&valign, "valign", "Vertical text alignment (top, center or bottom)")
+	border := flag.Int("border", 0, "Width of border drawn around the bar, in pixels")
+	borderColor := flag.Uint64("border-color", 0xFFFFFFFF, "Border color (0xAARRGGBB)")
+	flag.Lookup("border-color").DefValue = "0xFFFFFFFF"
+	autoHide := flag.Bool("autohide", false, "Show the bar only when pointer reaches the screen edge")
+	autoHideDelay := flag.Duration("autohide-delay", time.Second, "Time after which the bar hides once pointer leaves it")
+	hideOnFullscreen := flag.Bool("hide-on-fullscreen", false, "Keep the bar below other windows and hide it when fullscreen window is active")
+	connectRetries := flag.Int("connect-retries", 0, "Number of times to retry connecting to X")
+	connectRetryInterval := flag.Duration("connect-retry-interval", time.Second, "Time to wait before the first retry of connecting to X, doubled for every next one")
+	once := flag.Bool("once", false, "Draw the first input line and exit")
+	tabWidth := flag.Int("tab-width", 0, "Distance between tab stops in pixels, 0 leaves tabs to the font")
+	tracking := flag.Int("tracking", 0, "Number of pixels added between glyphs")
+	hoverHighlight := flag.Uint64("hover-highlight", 0, "Color blended over pieces pointer is over (0xAARRGGBB), 0 means no highlight")
+	flag.Lookup("hover-highlight").DefValue = "0x00000000"
+	textOutline := flag.Uint64("text-outline", 0, "Color of outline drawn around text (0xAARRGGBB), 0 means no outline")
+	flag.Lookup("text-outline").DefValue = "0x00000000"
+	bgImage := flag.String("bg-image", "", "Path to PNG, JPEG or GIF image covering the bar background")
+	var bgImageMode BgMode
+	flag.Var(&bgImageMode, "bg-image-mode", "How background image covers the bar (stretch or tile)")
+	dither := flag.Bool("dither", false, "Dither background image to avoid banding")
+	onceDelay := flag.Duration("once-delay", time.Second, "Time to keep the bar on screen before exiting with -once")
+	delimiter := flag.String("delimiter", "\\n", "String ending every input record, with Go escapes, e.g. \\x00")
+	framing := flag.String("framing", "line", "How input records are framed (line, or length for <n>\\n<n bytes>)")
+	format := flag.String("format", "gobar", "Input format (gobar, pango, i3bar, or auto to detect i3bar lines among gobar ones)")
+	placeholdersFlag := flag.Bool("placeholders", false, "Substitute {{TIME:<layout>}}, {{ENV:<name>}} and {{HOSTNAME}} in input lines")
+	perScreen := flag.Bool("per-screen", false, "Read input lines as <screen>|<text>, replacing only text of that screen")
+	fieldSep := flag.String("field-sep", "", "String separating plain text input fields, alternately left and right aligned, with Go escapes, e.g. \\t")
+	zonesFlag := flag.Bool("zones", false, "Read input lines as <zone>: <text>, replacing only pieces of that zone")
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve metrics in Prometheus format at, e.g. :9100")
+	clockLayout := flag.String("clock", "", "Time layout in Go format, with markup, to draw every second until there is any input, e.g. 15:04")
+	socket := flag.String("socket", "", "Path of a Unix socket to read input from, instead of stdin")
+	notifyReadyTarget := flag.String("notify-ready", "", "File to touch, or \"systemd\" to notify through $NOTIFY_SOCKET, once the first frame is drawn")
+	reportHover := flag.String("report-hover", "", "File or fifo to write name of the piece under pointer to whenever it changes, - for stdout")
+	overrideRedirect := flag.Bool("override-redirect", false, "Keep window manager from managing bar windows")
+	noEWMH := flag.Bool("no-ewmh", false, "Create plain override-redirect windows instead of EWMH docks")
+	clickCopy := flag.Bool("click-copy", false, "Copy pieces to the clipboard on clicking them")
+	clickThroughFlag := flag.Bool("clickthrough", false, "Let pointer events pass through the bar to windows beneath")
+	animFPS := flag.Float64("anim-fps", 4, "Frames per second spinners and urgent pieces are animated at, 0 stops them")
+	urgentColor := flag.Uint64("urgent-color", 0xFFFF0000, "Color backgrounds of urgent pieces pulse towards (0xAARRGGBB)")
+	flag.Lookup("urgent-color").DefValue = "0xFFFF0000"
+	urgentPeriod := flag.Duration("urgent-period", time.Second, "Time it takes urgent pieces to pulse once")
+	var accentLine AccentLine
+	flag.Var(&accentLine, "accent-line", "Color and width of line along the bar edge facing away from the screen edge (0xAARRGGBB[:<px>])")
+	mono := flag.Bool("mono", false, "Advance every character by the same cell width, that of 0 in the first font, lining up text like in a terminal")
+	fitHeight := flag.Bool("fit-height", false, "Scale fonts set inline down to fit into bar rows, instead of clipping them")
+	edgeBleed := flag.Bool("edge-bleed", false, "Extend backgrounds of the outermost pieces to the bar edges")
+	heightsFlag := flag.String("heights", "", "Comma separated list of bar heights to switch between on SIGUSR2")
+	single := flag.Bool("single", false, "Draw one bar spanning all monitors, instead of one per monitor")
+	gapX := flag.Int("gap-x", 0, "Pixels between the bar and left/right head edges")
+	gapY := flag.Int("gap-y", 0, "Pixels between the bar and top/bottom head edges")
+	respectStruts := flag.Bool("respect-struts", false, "Place the bar next to other docks instead of at the screen edge")
+	window := flag.Uint64("window", 0, "Id of an existing window to draw into, instead of creating dock windows")
+	printHeadsFlag := flag.Bool("print-heads", false, "Print monitors and bar windows placed on them and exit")
+	dumpOnly := flag.Bool("dump", false, "Print pieces every input line is parsed into instead of drawing")
+	measureOnly := flag.Bool("measure", false, "Print width of every input line per screen instead of drawing")
+	watchFontsFlag := flag.Bool("watch-fonts", false, "Look fonts up again when system fonts change")
+	flag.Var(&logLevel, "log-level", "Least important messages to log (debug, info, warn or error)")
+	flag.Var(quietFlag{}, "quiet", "Do not log anything but fatal errors")
+	versionFlag := flag.Bool("version", false, "Print version, commit and Go version gobar was built with and exit")
+	flag.Parse()
+
+	if *versionFlag {
+		fatal(printVersion(os.Stdout))
+		return
+	}
+
+	if *themeName != "" {
+		theme, err := loadTheme(*themeName)
+		fatal(err)
+		fatal(theme.apply(flag.CommandLine))
+	}
+
+	fonts, fontInfos, err := resolveFonts(fontDefs, *strictFonts)
+	fatal(err)
+
+	var geometries Geometries
+	fatal(geometries.Set(geometryDefs.value))
+
+	baselines := make([]int, len(fontInfos))
+	for i, info := range fontInfos {
+		baselines[i] = info.Baseline
+	}
+
+	if *listFontsFlag {
+		fatal(listFonts(os.Stdout, fontInfos))
+		return
+	}
+
+	delim, err := strconv.Unquote(`"` + *delimiter + `"`)
+	if err != nil || delim == "" {
+		fatal(fmt.Errorf("invalid delimiter `%s`", *delimiter))
+	}
+	// With custom delimiter, every record is a whole frame,
+	// with newlines in it starting new rows.
+	frames := delim != "\n"
+	switch *framing {
+	case "line":
+	case "length":
+		if delim != "\n" || *rows > 1 || *socket != "" || *dumpOnly || *measureOnly {
+			fatal(errors.New("-framing length cannot be used with -delimiter, -rows, -socket, -dump or -measure"))
+		}
+		frames = true
+	default:
+		fatal(fmt.Errorf("unknown input framing `%s`", *framing))
+	}
+
+	var parser Parser
+	switch *format {
+	case "gobar", "auto":
+		textParser := NewTextParser()
+		textParser.MultiLine = *rows > 1 || frames
+		parser = textParser
+		if *format == "auto" {
+			parser = &AutoParser{Text: textParser, I3bar: &I3barParser{}}
+		}
+	case "pango":
+		parser = &PangoParser{MultiLine: *rows > 1 || frames}
+	case "i3bar":
+		parser = &I3barParser{}
+	default:
+		fatal(fmt.Errorf("unknown input format `%s`", *format))
+	}
+	if *fieldSep != "" {
+		if *format != "gobar" {
+			fatal(errors.New("-field-sep cannot be used with -format"))
+		}
+		sep, err := strconv.Unquote(`"` + *fieldSep + `"`)
+		if err != nil || sep == "" || strings.Contains(sep, "\n") {
+			fatal(fmt.Errorf("invalid field separator `%s`", *fieldSep))
+		}
+		parser = &FieldParser{Sep: sep, MultiLine: *rows > 1 || frames}
+	}
+	// Clock gets drawn whole, without zones.
+	clockParser := parser
+	if *zonesFlag {
+		if *rows > 1 || frames {
+			fatal(errors.New("-zones cannot be used with -rows or -delimiter"))
+		}
+		parser = NewZoneParser(parser)
+	}
+	if *perScreen {
+		if *zonesFlag {
+			fatal(errors.New("-per-screen cannot be used with -zones"))
+		}
+		parser = NewScreenParser(parser)
+	}
+	var placeholderParser *PlaceholderParser
+	if *placeholdersFlag {
+		placeholderParser = NewPlaceholderParser(parser)
+		parser = placeholderParser
+	}
+
+	if *dumpOnly {
+		fatal(dump(os.Stdin, os.Stdout, delim, parser))
+		return
+	}
+
+	if *measureOnly {
+		fatal(measure(os.Stdin, os.Stdout, delim, parser, fonts, Options{Tracking: *tracking, TabWidth: *tabWidth}))
+		return
+	}
+
+	var bgImg image.Image
+	if *bgImage != "" {
+		img, err := loadImage(*bgImage)
+		fatal(err)
+		bgImg = img
+	}
+
+	position := TOP
+	if *bottom {
+		position = BOTTOM
+	}
+
+	X, err := connect(*connectRetries, *connectRetryInterval)
+	if err != nil {
+		fatal(NoDisplayError{err})
+	}
+
+	gap := image.Pt(*gapX, *gapY)
+	if *printHeadsFlag {
+		heads, err := xinerama.PhysicalHeads(X)
+		fatal(err)
+		if *single {
+			heads = spanHeads(heads)
+		}
+		maxHeight := xwindow.RootGeometry(X).Height()
+		var docks []ewmh.WmStrutPartial
+		if *respectStruts {
+			docks = dockStruts(X)
+		}
+		fatal(printHeads(os.Stdout, heads, placements(heads, geometries, position, maxHeight, docks, gap)))
+		return
+	}
+
+	var outline *xgraphics.BGRA
+	if *textOutline != 0 {
+		outline = NewBGRA(*textOutline)
+	}
+
+	var hoverReport io.Writer
+	if *reportHover != "" {
+		if *clickThroughFlag {
+			fatal(errors.New("-clickthrough cannot be used with -report-hover"))
+		}
+		hoverReport = os.Stdout
+		if *reportHover != "-" {
+			// Opened for reading too, so that opening a fifo
+			// does not wait for the other end.
+			file, err := os.OpenFile(expandPath(*reportHover), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+			fatal(err)
+			defer file.Close()
+			hoverReport = file
+		}
+	}
+
+	// Window ids are 32 bits long.
+	if *window > math.MaxUint32 {
+		fatal(fmt.Errorf("-window `0x%x` is not a valid window id", *window))
+	}
+
+	if *clickCopy && *clickThroughFlag {
+		fatal(errors.New("-clickthrough cannot be used with -click-copy"))
+	}
+
+	var highlight *xgraphics.BGRA
+	if *hoverHighlight != 0 {
+		if *clickThroughFlag {
+			fatal(errors.New("-clickthrough cannot be used with -hover-highlight"))
+		}
+		highlight = NewBGRA(*hoverHighlight)
+	}
+
+	bar := NewBar(X, geometries, position, fgColors, bgColors, fonts, Options{
+		Rows:             *rows,
+		Wrap:             *wrap,
+		VAlign:           valign,
+		Border:           *border,
+		BorderColor:      NewBGRA(*borderColor),
+		AutoHide:         *autoHide,
+		AutoHideDelay:    *autoHideDelay,
+		HideOnFullscreen: *hideOnFullscreen,
+		BgImage:          bgImg,
+		BgImageMode:      bgImageMode,
+		Dither:           *dither,
+		Tracking:         *tracking,
+		TabWidth:         *tabWidth,
+		NoAntialias:      noAntialias,
+		TextOutline:      outline,
+		HoverHighlight:   highlight,
+		HoverReport:      hoverReport,
+		RespectStruts:    *respectStruts,
+		Gap:              gap,
+		Single:           *single,
+		EdgeBleed:        *edgeBleed,
+		Mono:             *mono,
+		FitHeight:        *fitHeight,
+		AccentLine:       accentLine,
+		ClickThrough:     *clickThroughFlag,
+		ClickCopy:        *clickCopy,
+		NoEWMH:           *noEWMH,
+		OverrideRedirect: *overrideRedirect,
+		UrgentColor:      NewBGRA(*urgentColor),
+		UrgentPeriod:     *urgentPeriod,
+		Baselines:        baselines,
+		Window:           xproto.Window(*window),
+	})
+
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr)
+	}
+
+	stdin := make(chan []*TextPiece, stdinBacklog)
+	if *socket != "" {
+		listener, err := listenSocket(expandPath(*socket))
+		fatal(err)
+		defer listener.Close()
+		go serveSocket(listener, delim, *rows, parser, stdin)
+	} else {
+		// Reader is shared by all the reads, so that nothing it
+		// buffered is lost when reading goes on after an error.
+		reader := bufio.NewReader(os.Stdin)
+		go readUntilEOF(func() error {
+			if *framing == "length" {
+				return readFrames(reader, parser, stdin)
+			}
+			return readInput(reader, delim, *rows, parser, stdin)
+		}, stdin)
+	}
+
+	var clockTicker *time.Ticker
+	var clockTick <-chan time.Time
+	var clockPieces []*TextPiece
+	if *clockLayout != "" {
+		clockPieces = parseClock(clockParser, *clockLayout)
+		clockTicker = time.NewTicker(clockInterval)
+		clockTick = clockTicker.C
+		bar.Draw(clockText(clockPieces, time.Now()))
+	}
+
+	var placeholderTick <-chan time.Time
+	if placeholderParser != nil {
+		placeholderTick = time.NewTicker(placeholderInterval).C
+	}
+
+	var animTick <-chan time.Time
+	if *animFPS > 0 {
+		animTick = time.NewTicker(time.Duration(float64(time.Second) / *animFPS)).C
+	}
+
+	var autoHideTick <-chan time.Time
+	if *autoHide {
+		autoHideTick = time.NewTicker(autoHideInterval).C
+	}
+
+	fontChanges := make(chan struct{})
+	if *watchFontsFlag {
+		go watchFonts(fontDirs(), fontsWatchInterval, fontChanges)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	toggles := make(chan os.Signal, 1)
+	// Notify with no signals would relay all of them.
+	if len(toggleSignals) > 0 {
+		signal.Notify(toggles, toggleSignals...)
+	}
+	// Every SIGUSR2 resizes the bar to the next of heights.
+	var heights []uint16
+	nextHeight := 0
+	resizes := make(chan os.Signal, 1)
+	if *heightsFlag != "" {
+		for _, h := range strings.Split(*heightsFlag, ",") {
+			height, err := strconv.ParseUint(h, 10, 16)
+			if err != nil || height == 0 {
+				fatal(fmt.Errorf("invalid height `%s`", h))
+			}
+			heights = append(heights, uint16(height))
+		}
+		if len(resizeSignals) > 0 {
+			signal.Notify(resizes, resizeSignals...)
+		}
+	}
+
+	notified := *notifyReadyTarget == ""
+	pingBefore, pingAfter, pingQuit := xevent.MainPing(X)
+	for {
+		select {
+		case <-pingBefore:
+			<-pingAfter
+		case text, ok := <-stdin:
+			if !ok {
+				// Input ended, keep showing whatever is drawn already.
+				stdin = nil
+				continue
+			}
+			// Any input takes over from the clock for good.
+			if clockTicker != nil {
+				clockTicker.Stop()
+				clockTicker, clockTick = nil, nil
+			}
+			// Do not lag behind when input comes faster than it is drawn.
+			// With -once, it is the first line that gets drawn, though.
+			if !*once {
+				text = latest(stdin, text)
+			}
+			bar.Draw(text)
+			if !notified {
+				notified = true
+				// Make sure the frame actually reached X before telling.
+				X.Sync()
+				if err := notifyReady(*notifyReadyTarget); err != nil {
+					logf(ERROR, "Could not notify readiness: %s", err)
+				}
+			}
+			if *once {
+				// Make sure the frame actually reached X before waiting.
+				X.Sync()
+				time.Sleep(*onceDelay)
+				bar.Close()
+				return
+			}
+		case <-bar.redraws:
+			bar.redraw()
+		case now := <-clockTick:
+			bar.Draw(clockText(clockPieces, now))
+		case <-placeholderTick:
+			if text, ok := placeholderParser.Rescan(); ok {
+				bar.Draw(text)
+			}
+		case <-animTick:
+			bar.animate()
+		case now := <-autoHideTick:
+			bar.autoHide(now)
+		case <-resizes:
+			bar.resize(heights[nextHeight])
+			nextHeight = (nextHeight + 1) % len(heights)
+		case <-toggles:
+			bar.toggle()
+		case <-fontChanges:
+			bar.clearFonts()
+		case <-signals:
+			bar.Close()
+			return
 		case <-pingQuit:
 			return
 		}
@@ -22,11 +22,31 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/ewmh"
+	"github.com/jezek/xgbutil/xgraphics"
+	"github.com/jezek/xgbutil/xinerama"
+	"github.com/jezek/xgbutil/xrect"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
 )
 
 func TestGeometriesSet(t *testing.T) {
@@ -68,6 +88,13 @@ func TestGeometriesSet(t *testing.T) {
 			{22, 1, 20, 15},
 			nil,
 		}},
+		{"0x16+0-10", "", Geometries{
+			{0, 16, 0, -10},
+		}},
+		{"0x16+5+10,100x20+0-0", "", Geometries{
+			{0, 16, 5, 10},
+			{100, 20, 0, 0},
+		}},
 		{"wrongo", "Bad geometry `wrongo`, using default\n", Geometries{
 			{0, 16, 0, 0},
 		}},
@@ -88,9 +115,2039 @@ func TestGeometriesSet(t *testing.T) {
 		}
 	}
 
-	geometries := Geometries{{0, 16, 0, 0}}
+	for i, input := range []string{"0x16+0+0", "22x1+20+15", "0x16+0-10"} {
+		geometries := Geometries{}
+		geometries.Set(input)
+
+		assertEqual(t, input, input, geometries[0].String(), "GeometryString", i)
+	}
+
+	defaultHeight = 32
+	geometries := Geometries{}
+	geometries.Set("wrongo")
+	assertEqual(t, "wrongo", Geometries{{0, 32, 0, 0}}, geometries, "GeometriesSet_defaultHeight", -1)
+	places := placements(xinerama.Heads{xrect.New(0, 0, 100, 100)}, nil, TOP, 100, nil, image.Point{})
+	assertEqual(t, "", image.Rect(0, 0, 100, 32), places[0].rect, "GeometriesSet_defaultHeight", -1)
+	defaultHeight = 16
+	stderr.Reset()
+	log.SetOutput(os.Stderr)
+
+	geometries = Geometries{{0, 16, 0, 0}}
 	err := geometries.Set("")
 	assertEqualError(t, fmt.Errorf("geometries flag already set"), err, "GeometriesSet", -1)
 
+	var defs geometryDefs
+	assertEqual(t, "", `"0x16+0+0"`, defs.String(), "GeometryDefsSet", -1)
+	err = defs.Set("wrongo")
+	assertEqualError(t, nil, err, "GeometryDefsSet", -1)
+	assertEqual(t, "wrongo", "wrongo", defs.value, "GeometryDefsSet", -1)
+	err = defs.Set("0x16+0+0")
+	assertEqualError(t, fmt.Errorf("geometries flag already set"), err, "GeometryDefsSet", -1)
+
+	log.SetOutput(os.Stderr)
+}
+
+func TestResolveFonts(t *testing.T) {
+	tests := []struct {
+		input  string
+		strict bool
+		faces  int
+		err    bool
+	}{
+		{"", false, 1, false},
+		{"", true, 1, false},
+		{"/nonexistent/font.ttf", false, 1, false},
+		{"/nonexistent/font.ttf,/nonexistent/other.ttf", false, 2, false},
+		{"/nonexistent/font.ttf", true, 0, true},
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+
+	for i, test := range tests {
+		var defs fontDefs
+		if test.input != "" {
+			defs.Set(test.input)
+		}
+
+		faces, infos, err := resolveFonts(defs, test.strict)
+
+		assertEqual(t, test.input, test.faces, len(faces), "ResolveFonts", i)
+		assertEqual(t, test.input, test.faces, len(infos), "ResolveFonts", i)
+		assertEqual(t, test.input, test.err, err != nil, "ResolveFonts", i)
+	}
+	log.SetOutput(os.Stderr)
+}
+
+func TestBarFace(t *testing.T) {
+	tests := []struct {
+		fonts  fonts
+		index  uint
+		logs   string
+		output font.Face
+	}{
+		{fonts{inconsolata.Bold8x16}, 0, "", inconsolata.Bold8x16},
+		{fonts{inconsolata.Bold8x16, inconsolata.Regular8x16}, 1, "", inconsolata.Regular8x16},
+		{fonts{inconsolata.Bold8x16}, 3, "Invalid font index `3`, using `0`\n", inconsolata.Bold8x16},
+		{fonts{}, 0, "No fonts available for index `0`, using `inconsolata regular 8x16`\n", inconsolata.Regular8x16},
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+
+	for i, test := range tests {
+		bar := &Bar{Fonts: test.fonts}
+
+		// Second call should not log again.
+		for j := 0; j < 2; j++ {
+			face := bar.face(test.index)
+			logs, _ := stderr.ReadString('\n')
+
+			assertEqual(t, test.index, test.output, face, "BarFace", i)
+			if j == 0 && test.logs != "" {
+				assertEqual(t, test.index, test.logs, logs[20:], "BarFace", i)
+			} else {
+				assertEqual(t, test.index, "", logs, "BarFace", i)
+			}
+		}
+	}
+
+	log.SetOutput(os.Stderr)
+}
+
+func TestResizedGeometries(t *testing.T) {
+	geometries := []*Geometry{{Width: 100, Height: 16, X: 10, Y: -5}, nil, {Height: 20}}
+
+	resized := resizedGeometries(geometries, 32)
+
+	expected := []*Geometry{{Width: 100, Height: 32, X: 10, Y: -5}, nil, {Height: 32}}
+	assertEqual(t, geometries, expected, resized, "ResizedGeometries", 0)
+	// Geometries resized are copies.
+	assertEqual(t, geometries, uint16(16), geometries[0].Height, "ResizedGeometries", 0)
+
+	// Without geometries bars are on every head, resized as well.
+	assertEqual(t, nil, []*Geometry{{Height: 32}}, resizedGeometries(nil, 32), "ResizedGeometries", 1)
+}
+
+func TestSpanHeads(t *testing.T) {
+	tests := []struct {
+		input  xinerama.Heads
+		output xinerama.Heads
+	}{
+		{nil, nil},
+		{xinerama.Heads{xrect.New(0, 0, 1920, 1080)}, xinerama.Heads{xrect.New(0, 0, 1920, 1080)}},
+		{
+			xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)},
+			xinerama.Heads{xrect.New(0, 0, 3200, 1080)},
+		},
+		{
+			xinerama.Heads{xrect.New(1920, 100, 1280, 1024), xrect.New(0, 0, 1920, 1080)},
+			xinerama.Heads{xrect.New(0, 0, 3200, 1124)},
+		},
+	}
+
+	for i, test := range tests {
+		actual := spanHeads(test.input)
+		assertEqual(t, test.input, true, headsEqual(test.output, actual), "SpanHeads", i)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	// Every glyph of inconsolata is 8 pixels wide.
+	tests := []struct {
+		input  string
+		first  int
+		width  int
+		output []string
+	}{
+		{"", 80, 80, []string{""}},
+		{"test", 80, 80, []string{"test"}},
+		{"test test", 80, 80, []string{"test test"}},
+		{"test test", 64, 80, []string{"test", "test"}},
+		{"test test test", 72, 80, []string{"test test", "test"}},
+		{"test test", 16, 80, []string{"", "test test"}},
+		{"testtest", 32, 32, []string{"test", "test"}},
+		{"testtesttest", 16, 32, []string{"", "test", "test", "test"}},
+		{"test ", 40, 40, []string{"test "}},
+		{"test  test", 32, 32, []string{"test", "test"}},
+		{"zażółć", 24, 24, []string{"zaż", "ółć"}},
+		{"test", 0, 0, []string{"t", "e", "s", "t"}},
+	}
+
+	for i, test := range tests {
+		lines := wrapText(
+			inconsolata.Regular8x16, test.input,
+			fixed.I(test.first), fixed.I(test.width),
+		)
+
+		assertEqual(t, test.input, test.output, lines, "WrapText", i)
+	}
+}
+
+func TestVAlignSet(t *testing.T) {
+	tests := []struct {
+		input  string
+		output VAlign
+		err    error
+	}{
+		{"top", VTOP, nil},
+		{"center", VCENTER, nil},
+		{"bottom", VBOTTOM, nil},
+		{"wrongo", VCENTER, fmt.Errorf("invalid vertical alignment `wrongo`")},
+	}
+
+	for i, test := range tests {
+		var valign VAlign
+
+		err := valign.Set(test.input)
+
+		assertEqual(t, test.input, test.output, valign, "VAlignSet", i)
+		assertEqualError(t, test.err, err, "VAlignSet", i)
+	}
+}
+
+func TestTextY(t *testing.T) {
+	face := inconsolata.Regular8x16
+	metrics := face.Metrics()
+	// xgraphics.Image.Text moves the text down by that much.
+	offset := metrics.Height - fixed.I(metrics.CaretSlope.Y*2)
+
+	tests := []struct {
+		valign   VAlign
+		y        int
+		height   int
+		baseline fixed.Int26_6
+	}{
+		{VTOP, 0, 32, metrics.Ascent},
+		{VTOP, 10, 32, fixed.I(10) + metrics.Ascent},
+		{VBOTTOM, 0, 32, fixed.I(32) - metrics.Descent},
+		{VBOTTOM, 10, 32, fixed.I(42) - metrics.Descent},
+		{VCENTER, 0, 32, (fixed.I(32) + metrics.Ascent - metrics.Descent) / 2},
+		{VCENTER, 10, 32, fixed.I(10) + (fixed.I(32)+metrics.Ascent-metrics.Descent)/2},
+	}
+
+	for i, test := range tests {
+		y := textY(face, test.valign, test.y, test.height)
+
+		assertEqual(t, test.valign, test.baseline, y+offset, "TextY", i)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"", ""},
+		{"test\n", "0\t32\n"},
+		{"test", "0\t32\n"},
+		{"test\n{ARtest}", "0\t32\n0\t32\n"},
+		{"{S1test}", "0\t0\n1\t32\n"},
+		{"test{S1test}", "0\t32\n1\t64\n"},
+		{"{S-2test}", "0\t32\n1\t32\n2\t0\n"},
+		{"{MW50test}", "0\t50\n"},
+		{"test{GAP10}test", "0\t74\n"},
+		{"{PX5test}", "0\t37\n"},
+		{"{COL1t}{COL1test}{COL2t}", "0\t72\n"},
+		{"{F1test}", "0\t32\n"},
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+
+	for i, test := range tests {
+		var stdout bytes.Buffer
+
+		err := measure(
+			strings.NewReader(test.input), &stdout, "\n",
+			NewTextParser(), fonts{inconsolata.Regular8x16}, Options{},
+		)
+
+		assertEqualError(t, nil, err, "Measure", i)
+		assertEqual(t, test.input, test.output, stdout.String(), "Measure", i)
+	}
+
 	log.SetOutput(os.Stderr)
 }
+
+func TestDump(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"", ""},
+		{"test\n", "\"test\"\n\n"},
+		{"test1{F1test2}\n{ARtest3}", "\"test1\"\n\"test2\" font=1\n\n\"test3\" align=right\n\n"},
+		{"{MWxtest}\n", "\"{MWxtest\"\nerror: invalid `{MWxtest`: strconv.Atoi: parsing \"xtest\": invalid syntax\n\n"},
+	}
+
+	for i, test := range tests {
+		var stdout bytes.Buffer
+
+		err := dump(strings.NewReader(test.input), &stdout, "\n", NewTextParser())
+
+		assertEqualError(t, nil, err, "Dump", i)
+		assertEqual(t, test.input, test.output, stdout.String(), "Dump", i)
+	}
+}
+
+func TestDump_wrapped(t *testing.T) {
+	input := "{MWxtest}\n"
+	problems := "error: invalid `{MWxtest`: strconv.Atoi: parsing \"xtest\": invalid syntax\n\n"
+	tests := []Parser{
+		NewZoneParser(NewTextParser()),
+		NewScreenParser(NewTextParser()),
+		NewPlaceholderParser(NewTextParser()),
+		&AutoParser{Text: NewTextParser(), I3bar: &I3barParser{}},
+		NewPlaceholderParser(NewZoneParser(NewTextParser())),
+	}
+
+	for i, parser := range tests {
+		var stdout bytes.Buffer
+
+		err := dump(strings.NewReader(input), &stdout, "\n", parser)
+
+		assertEqualError(t, nil, err, "Dump_wrapped", i)
+		if !strings.HasSuffix(stdout.String(), problems) {
+			t.Errorf("Dump_wrapped:%d expected problems found by the wrapped parser, got `%s`\n", i, stdout.String())
+		}
+	}
+}
+
+func TestStruts(t *testing.T) {
+	tests := []struct {
+		head     xrect.Rect
+		x        int
+		y        int
+		position Position
+		strutP   *ewmh.WmStrutPartial
+		strut    *ewmh.WmStrut
+	}{
+		{xrect.New(0, 0, 1920, 1080), 0, 0, TOP,
+			&ewmh.WmStrutPartial{Top: 16, TopStartX: 0, TopEndX: 1920},
+			&ewmh.WmStrut{Top: 16},
+		},
+		{xrect.New(0, 0, 1920, 1080), 100, 0, TOP,
+			&ewmh.WmStrutPartial{Top: 16, TopStartX: 100, TopEndX: 2020},
+			&ewmh.WmStrut{Top: 16},
+		},
+		{xrect.New(1920, 0, 1920, 1080), 0, 0, TOP,
+			&ewmh.WmStrutPartial{Top: 16, TopStartX: 1920, TopEndX: 3840},
+			&ewmh.WmStrut{Top: 16},
+		},
+		{xrect.New(1920, 0, 1920, 1080), 100, 1064, BOTTOM,
+			&ewmh.WmStrutPartial{Bottom: 16, BottomStartX: 2020, BottomEndX: 3940},
+			&ewmh.WmStrut{Bottom: 16},
+		},
+	}
+
+	for i, test := range tests {
+		strutP, strut := struts(test.head, test.x, test.y, 1920, 16, 1080, test.position)
+
+		assertEqual(t, test.head, test.strutP, strutP, "Struts", i)
+		assertEqual(t, test.head, test.strut, strut, "Struts", i)
+	}
+}
+
+func TestPlacements(t *testing.T) {
+	heads := xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)}
+	docks := []ewmh.WmStrutPartial{{Top: 20, TopEndX: 3199, Bottom: 30, BottomEndX: 3199}}
+	tests := []struct {
+		geometries []*Geometry
+		position   Position
+		docks      []ewmh.WmStrutPartial
+		gap        image.Point
+		heads      []int
+		rects      []image.Rectangle
+	}{
+		{nil, TOP, nil, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 0, 1920, 16), image.Rect(1920, 0, 3200, 16),
+		}},
+		{[]*Geometry{{X: 10, Y: 5, Width: 100, Height: 20}}, TOP, nil, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(10, 5, 110, 25), image.Rect(1930, 5, 2030, 25),
+		}},
+		{[]*Geometry{{Height: 16}}, BOTTOM, nil, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 1064, 1920, 1080), image.Rect(1920, 1008, 3200, 1024),
+		}},
+		{[]*Geometry{nil, {Height: 16}}, TOP, nil, image.Point{}, []int{1}, []image.Rectangle{
+			image.Rect(1920, 0, 3200, 16),
+		}},
+		{[]*Geometry{{Height: 16}, nil}, TOP, nil, image.Point{}, []int{0}, []image.Rectangle{
+			image.Rect(0, 0, 1920, 16),
+		}},
+		// Negative offset counts from the opposite edge.
+		{[]*Geometry{{Height: 16, Y: -10}}, TOP, nil, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 1054, 1920, 1070), image.Rect(1920, 998, 3200, 1014),
+		}},
+		{[]*Geometry{{Height: 16, Y: -10}}, BOTTOM, nil, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 10, 1920, 26), image.Rect(1920, 10, 3200, 26),
+		}},
+		// Other docks reserved 20 pixels at the top and 30 at the bottom.
+		{[]*Geometry{{Height: 16}}, TOP, docks, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 20, 1920, 36), image.Rect(1920, 20, 3200, 36),
+		}},
+		{[]*Geometry{{Height: 16}}, BOTTOM, docks, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 1034, 1920, 1050), image.Rect(1920, 1008, 3200, 1024),
+		}},
+		{[]*Geometry{{Height: 16}}, BOTTOM, []ewmh.WmStrutPartial{{Bottom: 80, BottomEndX: 3199}}, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 984, 1920, 1000), image.Rect(1920, 984, 3200, 1000),
+		}},
+		// Docks on one head leave the other ones alone, whatever their size.
+		{[]*Geometry{{Height: 16}}, BOTTOM, []ewmh.WmStrutPartial{{Bottom: 76, BottomStartX: 1920, BottomEndX: 3199}}, image.Point{}, []int{0, 1}, []image.Rectangle{
+			image.Rect(0, 1064, 1920, 1080), image.Rect(1920, 988, 3200, 1004),
+		}},
+		// Gaps inset the bar from head edges.
+		{[]*Geometry{{Height: 16}}, TOP, nil, image.Pt(8, 4), []int{0, 1}, []image.Rectangle{
+			image.Rect(8, 4, 1912, 20), image.Rect(1928, 4, 3192, 20),
+		}},
+		{[]*Geometry{{Height: 16}}, BOTTOM, nil, image.Pt(8, 4), []int{0, 1}, []image.Rectangle{
+			image.Rect(8, 1060, 1912, 1076), image.Rect(1928, 1004, 3192, 1020),
+		}},
+		{[]*Geometry{{X: 10, Width: 100, Height: 0}}, TOP, nil, image.Pt(8, 4), []int{0, 1}, []image.Rectangle{
+			image.Rect(18, 4, 118, 1076), image.Rect(1938, 4, 2038, 1020),
+		}},
+		// Gaps bigger than the head still leave a pixel of bar.
+		{[]*Geometry{{Height: 16}}, TOP, nil, image.Pt(1000, 0), []int{0, 1}, []image.Rectangle{
+			image.Rect(1000, 0, 1001, 16), image.Rect(2920, 0, 2921, 16),
+		}},
+	}
+
+	for i, test := range tests {
+		places := placements(heads, test.geometries, test.position, 1080, test.docks, test.gap)
+
+		var actualHeads []int
+		var actualRects []image.Rectangle
+		for _, place := range places {
+			actualHeads = append(actualHeads, place.head)
+			actualRects = append(actualRects, place.rect)
+		}
+		assertEqual(t, test.geometries, test.heads, actualHeads, "Placements", i)
+		assertEqual(t, test.geometries, test.rects, actualRects, "Placements", i)
+	}
+}
+
+func TestBarWindowAttributes(t *testing.T) {
+	highlight := &xgraphics.BGRA{A: 0xFF}
+	tests := []struct {
+		options Options
+		mask    int
+		values  []uint32
+	}{
+		{Options{}, 0, nil},
+		{Options{HoverHighlight: highlight}, 0, nil},
+		{
+			Options{OverrideRedirect: true},
+			xproto.CwOverrideRedirect | xproto.CwEventMask,
+			[]uint32{1, xproto.EventMaskNoEvent},
+		},
+		{
+			Options{NoEWMH: true},
+			xproto.CwOverrideRedirect | xproto.CwEventMask,
+			[]uint32{1, xproto.EventMaskNoEvent},
+		},
+		{
+			Options{OverrideRedirect: true, HoverHighlight: highlight},
+			xproto.CwOverrideRedirect | xproto.CwEventMask,
+			[]uint32{1, xproto.EventMaskPointerMotion | xproto.EventMaskLeaveWindow},
+		},
+		{
+			Options{NoEWMH: true, ClickCopy: true},
+			xproto.CwOverrideRedirect | xproto.CwEventMask,
+			[]uint32{1, xproto.EventMaskButtonPress},
+		},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{Options: test.options}
+		mask, values := bar.windowAttributes()
+		assertEqual(t, test.options, test.mask, mask, "BarWindowAttributes", i)
+		assertEqual(t, test.options, test.values, values, "BarWindowAttributes", i)
+	}
+}
+
+func TestOverlapping(t *testing.T) {
+	tests := []struct {
+		heads      xinerama.Heads
+		geometries []*Geometry
+		output     [][2]int
+	}{
+		{xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)}, nil, nil},
+		// Cloned monitors.
+		{xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(0, 0, 1920, 1080)}, nil, [][2]int{{0, 1}}},
+		{
+			xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)},
+			[]*Geometry{{X: 1000, Width: 1000, Height: 16}, {Height: 16}},
+			[][2]int{{0, 1}},
+		},
+		{
+			xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)},
+			[]*Geometry{{X: 1000, Width: 1000, Height: 16}, {Height: 16, Y: 20}},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		places := placements(test.heads, test.geometries, TOP, 1080, nil, image.Point{})
+		assertEqual(t, test.geometries, test.output, overlapping(places), "Overlapping", i)
+	}
+}
+
+func TestPrintHeads(t *testing.T) {
+	heads := xinerama.Heads{xrect.New(0, 0, 1920, 1080), xrect.New(1920, 0, 1280, 1024)}
+	tests := []struct {
+		input  []*Geometry
+		output string
+	}{
+		{nil, "0\t0,0,1920,1080\t0,0,1920,16\n1\t1920,0,1280,1024\t1920,0,1280,16\n"},
+		{[]*Geometry{nil, {Width: 100, Height: 20}}, "0\t0,0,1920,1080\t-\n1\t1920,0,1280,1024\t1920,0,100,20\n"},
+	}
+
+	for i, test := range tests {
+		var out strings.Builder
+		err := printHeads(&out, heads, placements(heads, test.input, TOP, 1080, nil, image.Point{}))
+
+		assertEqual(t, test.input, nil, err, "PrintHeads", i)
+		assertEqual(t, test.input, test.output, out.String(), "PrintHeads", i)
+	}
+}
+
+func TestDrawBorder(t *testing.T) {
+	bg := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	border := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	img := &xgraphics.Image{
+		Pix:    make([]uint8, 4*6*5),
+		Stride: 4 * 6,
+		Rect:   image.Rect(0, 0, 6, 5),
+	}
+	img.For(func(x, y int) xgraphics.BGRA { return bg })
+
+	drawBorder(img, 2, &border)
+
+	expected := []string{
+		"######",
+		"######",
+		"##..##",
+		"######",
+		"######",
+	}
+	for y, line := range expected {
+		for x, c := range line {
+			color := bg
+			if c == '#' {
+				color = border
+			}
+			assertEqual(t, image.Pt(x, y), color, img.At(x, y), "DrawBorder", 0)
+		}
+	}
+}
+
+func TestFatal(t *testing.T) {
+	tests := []struct {
+		input error
+		code  int
+		msg   string
+	}{
+		{fmt.Errorf("test"), exitFailure, "test"},
+		{NoDisplayError{fmt.Errorf("test")}, exitNoDisplay, "no X display (test); set DISPLAY or use -measure"},
+		{fmt.Errorf("wrapped: %w", NoDisplayError{fmt.Errorf("test")}), exitNoDisplay, "wrapped: no X display (test); set DISPLAY or use -measure"},
+	}
+
+	defer func(orig func(int, string)) { exitWith = orig }(exitWith)
+
+	for i, test := range tests {
+		var code int
+		var msg string
+		exitWith = func(c int, m string) { code, msg = c, m }
+
+		fatal(test.input)
+
+		assertEqual(t, test.input, test.code, code, "Fatal", i)
+		assertEqual(t, test.input, test.msg, msg, "Fatal", i)
+	}
+
+	exitWith = func(int, string) { t.Errorf("Fatal: unexpected exit on nil error") }
+	fatal(nil)
+}
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		failures int
+		retries  int
+		err      bool
+		sleeps   []time.Duration
+	}{
+		{0, 0, false, nil},
+		{1, 0, true, nil},
+		{2, 3, false, []time.Duration{time.Second, 2 * time.Second}},
+		{5, 3, true, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+	}
+
+	defer func(orig func() (*xgbutil.XUtil, error)) { newConn = orig }(newConn)
+	defer func(orig func(time.Duration)) { sleep = orig }(sleep)
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	for i, test := range tests {
+		attempts := 0
+		newConn = func() (*xgbutil.XUtil, error) {
+			attempts++
+			if attempts <= test.failures {
+				return nil, fmt.Errorf("test")
+			}
+			return &xgbutil.XUtil{}, nil
+		}
+		var sleeps []time.Duration
+		sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+		X, err := connect(test.retries, time.Second)
+
+		assertEqual(t, test.failures, test.err, err != nil, "Connect", i)
+		assertEqual(t, test.failures, !test.err, X != nil, "Connect", i)
+		assertEqual(t, test.failures, test.sleeps, sleeps, "Connect", i)
+	}
+}
+
+// newTestBar returns a bar with canvases for the given geometries,
+// drawing black on black with the bundled font, without talking to X.
+func newTestBar(t testing.TB, geometries ...*Geometry) *Bar {
+	t.Helper()
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	bar := &Bar{
+		X:          &xgbutil.XUtil{},
+		Geometries: geometries,
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+	}
+	bar.createCanvases()
+	return bar
+}
+
+func TestBarCompose(t *testing.T) {
+	bg := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 80, Height: 16}, &Geometry{Width: 40, Height: 16})
+	text := []*TextPiece{
+		{Text: " ", Background: &red},
+		{Text: " ", Background: &blue, Screens: []uint{1}},
+		{Text: "  ", Background: &blue, Align: RIGHT},
+	}
+	tests := []struct {
+		screen uint
+		output string
+	}{
+		{0, "r.......bb"},
+		{1, "rb.bb"},
+	}
+
+	pieces := bar.resolve(text, len(bar.Geometries))
+	for i, test := range tests {
+		// Composing twice makes sure canvases are reused cleanly.
+		bar.compose(test.screen, pieces)
+		bar.compose(test.screen, pieces)
+		img := bar.canvases[test.screen].img
+
+		// Every char stands for a single 8px wide glyph cell.
+		for cell, c := range test.output {
+			color := map[rune]xgraphics.BGRA{'.': bg, 'r': red, 'b': blue}[c]
+			assertEqual(t, test.screen, color, img.At(cell*8+4, 0), "BarCompose", i)
+		}
+	}
+
+	// Pieces themselves stay untouched.
+	assertEqual(t, nil, (*xgraphics.BGRA)(nil), text[0].Foreground, "BarCompose", -1)
+}
+
+func BenchmarkBarCompose(b *testing.B) {
+	bar := newTestBar(b, &Geometry{Width: 1920, Height: 16})
+	pieces := bar.resolve(NewTextParser().Scan(strings.NewReader(
+		"{F0workspace 1} {CF0xFFFF0000load 0.42}{AR2026-01-01 12:00:00}",
+	)), 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bar.compose(0, pieces)
+	}
+}
+
+func BenchmarkBarResolve(b *testing.B) {
+	face, err := parseFontFace(bytes.NewReader(goregular.TTF), 12, fontOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	bar := &Bar{Fonts: fonts{face}}
+	text := NewTextParser().Scan(strings.NewReader(
+		"{F0workspace 1} {CF0xFFFF0000load 0.42}{AR2026-01-01 12:00:00}",
+	))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bar.resolve(text, 1)
+	}
+}
+
+func TestColorsSet(t *testing.T) {
+	red := NewBGRA(0xFFFF0000)
+	blue := NewBGRA(0x800000FF)
+	tests := []struct {
+		input  string
+		output colors
+		str    string
+		err    error
+	}{
+		{"0xFFFF0000", colors{red}, "0xFFFF0000", nil},
+		{"0xFFFF0000,0x800000FF", colors{red, blue}, "0xFFFF0000,0x800000FF", nil},
+		{"0xFFFF0000,wrongo", colors{NewBGRA(0xFF000000)}, "0xFF000000", fmt.Errorf("invalid color `wrongo`")},
+	}
+
+	for i, test := range tests {
+		c := colors{NewBGRA(0xFF000000)}
+
+		err := c.Set(test.input)
+
+		assertEqual(t, test.input, test.output, c, "ColorsSet", i)
+		assertEqual(t, test.input, test.str, c.String(), "ColorsSet", i)
+		assertEqualError(t, test.err, err, "ColorsSet", i)
+	}
+}
+
+func TestAccentLineSet(t *testing.T) {
+	red := NewBGRA(0xFFFF0000)
+	tests := []struct {
+		input  string
+		output AccentLine
+		str    string
+		err    error
+	}{
+		{"0xFFFF0000:2", AccentLine{red, 2}, "0xFFFF0000:2", nil},
+		{"0xFFFF0000", AccentLine{red, 1}, "0xFFFF0000:1", nil},
+		{"wrongo:2", AccentLine{}, "", fmt.Errorf("invalid color `wrongo`")},
+		{"0xFFFF0000:0", AccentLine{}, "", fmt.Errorf("invalid accent line width `0`")},
+		{"0xFFFF0000:x", AccentLine{}, "", fmt.Errorf("invalid accent line width `x`")},
+	}
+
+	for i, test := range tests {
+		var a AccentLine
+
+		err := a.Set(test.input)
+
+		assertEqual(t, test.input, test.output, a, "AccentLineSet", i)
+		assertEqual(t, test.input, test.str, a.String(), "AccentLineSet", i)
+		assertEqualError(t, test.err, err, "AccentLineSet", i)
+	}
+}
+
+func TestColorsAt(t *testing.T) {
+	red := NewBGRA(0xFFFF0000)
+	blue := NewBGRA(0xFF0000FF)
+	c := colors{red, blue}
+
+	for i, expected := range []*xgraphics.BGRA{red, blue, blue} {
+		assertEqual(t, i, expected, c.at(uint(i)), "ColorsAt", i)
+	}
+}
+
+func TestBarCompose_screenColors(t *testing.T) {
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 8, Height: 16}, &Geometry{Width: 8, Height: 16}, &Geometry{Width: 8, Height: 16})
+	bar.Foreground = colors{&blue, &red}
+	bar.Background = colors{&red, &blue}
+
+	// Underline is drawn with foreground color across the whole cell.
+	pieces := bar.resolve([]*TextPiece{{Text: " ", Underline: true}}, 3)
+	for i, expected := range []xgraphics.BGRA{blue, red, red} {
+		bar.compose(uint(i), pieces)
+
+		underlined := false
+		for y := 0; y < 16; y++ {
+			underlined = underlined || bar.canvases[i].img.At(4, y) == expected
+		}
+		assertEqual(t, i, true, underlined, "BarCompose_screenColors", i)
+	}
+
+	pieces = bar.resolve([]*TextPiece{{Text: " "}}, 3)
+	for i, expected := range []xgraphics.BGRA{red, blue, blue} {
+		bar.compose(uint(i), pieces)
+
+		assertEqual(t, i, expected, bar.canvases[i].img.At(4, 8), "BarCompose_screenColors", i)
+	}
+}
+
+func TestBarCompose_rightAlignedScreens(t *testing.T) {
+	bg := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	green := xgraphics.BGRA{B: 0x00, G: 0xFF, R: 0x00, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 32, Height: 16}, &Geometry{Width: 32, Height: 16})
+
+	// As scanned from `{AR{CBgreen }{S1{CBblue }}{CBred }}`.
+	text := []*TextPiece{
+		{Text: " ", Background: &red, Align: RIGHT},
+		{Text: " ", Background: &blue, Align: RIGHT, Screens: []uint{1}},
+		{Text: " ", Background: &green, Align: RIGHT},
+	}
+	tests := []struct {
+		screen uint
+		output []xgraphics.BGRA
+	}{
+		// Piece restricted to screen 1 does not move anything on screen 0.
+		{0, []xgraphics.BGRA{bg, bg, green, red}},
+		{1, []xgraphics.BGRA{bg, green, blue, red}},
+	}
+
+	pieces := bar.resolve(text, len(bar.Geometries))
+	for i, test := range tests {
+		bar.compose(test.screen, pieces)
+
+		for cell, color := range test.output {
+			assertEqual(t, test.screen, color, bar.canvases[test.screen].img.At(cell*8+4, 0), "BarCompose_rightAlignedScreens", i)
+		}
+	}
+}
+
+func TestBarWarnTall(t *testing.T) {
+	tests := []struct {
+		heights []uint16
+		rows    int
+		warns   int
+	}{
+		{[]uint16{16}, 1, 0},
+		{[]uint16{12}, 1, 1},
+		{[]uint16{16, 12}, 1, 1},
+		{[]uint16{32}, 2, 0},
+		{[]uint16{32}, 3, 1},
+		{nil, 1, 0},
+	}
+
+	for i, test := range tests {
+		var stderr bytes.Buffer
+		log.SetOutput(&stderr)
+
+		bar := &Bar{Fonts: fonts{inconsolata.Regular8x16}, Options: Options{Rows: test.rows}}
+		for _, height := range test.heights {
+			bar.Geometries = append(bar.Geometries, &Geometry{Width: 100, Height: height})
+		}
+		bar.resolve([]*TextPiece{{Text: "test1"}, {Text: "test2"}}, len(test.heights))
+
+		assertEqual(t, test.heights, test.warns, strings.Count(stderr.String(), "clipping it"), "BarWarnTall", i)
+	}
+	log.SetOutput(os.Stderr)
+}
+
+func TestBarLookupFace_fitHeight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goregular.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		height uint16
+		output int
+	}{
+		{16, 16},
+		// Resized bars get fonts fitted to their new height.
+		{12, 12},
+		{32, 28},
+		{16, 16},
+	}
+
+	bar := &Bar{Fonts: fonts{inconsolata.Regular8x16}, Options: Options{FitHeight: true}}
+	piece := &TextPiece{FontName: path + ":24"}
+	for i, test := range tests {
+		bar.Geometries = []*Geometry{{Width: 100, Height: test.height}}
+
+		assertEqual(t, test.height, test.output, faceHeight(bar.lookupFace(piece)), "BarLookupFace_fitHeight", i)
+	}
+}
+
+func TestBarPieceFace_tracking(t *testing.T) {
+	tests := []struct {
+		tracking int
+		text     string
+		output   fixed.Int26_6
+	}{
+		{0, "test", fixed.I(32)},
+		{2, "test", fixed.I(38)},
+		{2, "t", fixed.I(8)},
+		{-1, "test", fixed.I(29)},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{Fonts: fonts{inconsolata.Regular8x16}}
+		bar.Tracking = test.tracking
+		piece := &TextPiece{Text: test.text}
+
+		face := bar.pieceFace(piece)
+
+		assertEqual(t, test.text, test.output, font.MeasureString(face, test.text), "BarPieceFace_tracking", i)
+		// Faces keep their identity, e.g. for caching widths.
+		assertEqual(t, test.text, true, face == bar.pieceFace(piece), "BarPieceFace_tracking", i)
+	}
+}
+
+func TestBarPieceFace_mono(t *testing.T) {
+	face, err := parseFontFace(bytes.NewReader(goregular.TTF), 12, fontOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero, _ := face.GlyphAdvance('0')
+	cell := fixed.I(zero.Round())
+
+	tests := []struct {
+		tracking int
+		text     string
+		output   fixed.Int26_6
+	}{
+		{0, "0000", 4 * cell},
+		{0, "iiii", 4 * cell},
+		{0, "WAVE", 4 * cell},
+		{2, "il", 2*cell + fixed.I(2)},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{Fonts: fonts{face}, Options: Options{Mono: true}}
+		bar.Tracking = test.tracking
+		piece := &TextPiece{Text: test.text}
+
+		assertEqual(t, test.text, test.output, font.MeasureString(bar.pieceFace(piece), test.text), "BarPieceFace_mono", i)
+	}
+}
+
+func TestTabSegments(t *testing.T) {
+	face := inconsolata.Regular8x16
+	tests := []struct {
+		text     string
+		tabWidth int
+		segments []tabSegment
+		width    int
+	}{
+		{"te\tst", 0, []tabSegment{{"te\tst", 0}}, 40},
+		{"test", 32, []tabSegment{{"test", 0}}, 32},
+		{"te\tst", 32, []tabSegment{{"te", 0}, {"st", fixed.I(32)}}, 48},
+		{"test\tst", 32, []tabSegment{{"test", 0}, {"st", fixed.I(64)}}, 80},
+		{"\t\tst", 32, []tabSegment{{"st", fixed.I(64)}}, 80},
+		{"te\t", 32, []tabSegment{{"te", 0}}, 32},
+	}
+
+	for i, test := range tests {
+		segments, width := tabSegments(face, test.text, fixed.I(test.tabWidth))
+
+		assertEqual(t, test.text, test.segments, segments, "TabSegments", i)
+		assertEqual(t, test.text, fixed.I(test.width), width, "TabSegments", i)
+	}
+}
+
+func TestBarCompose_tabs(t *testing.T) {
+	bar := newTestBar(t, &Geometry{Width: 128, Height: 16})
+	bar.TabWidth = 32
+
+	text := []*TextPiece{{Text: "|"}, {Text: "\t|"}, {Text: "|"}}
+	bar.compose(0, bar.resolve(text, 1))
+
+	// Tab stops count from the piece start, the same as when measured.
+	expected := []image.Rectangle{image.Rect(0, 0, 8, 16), image.Rect(8, 0, 48, 16), image.Rect(48, 0, 56, 16)}
+	assertEqual(t, text, expected, bar.canvases[0].spans, "BarCompose_tabs", 0)
+	assertEqual(t, text, []fixed.Int26_6{fixed.I(56)}, bar.Measure(text, 1), "BarCompose_tabs", 0)
+}
+
+func TestExpandPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	os.Setenv("GOBAR_TEST_DIR", "/tmp/fonts")
+	defer os.Unsetenv("GOBAR_TEST_DIR")
+
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"/usr/share/fonts/font.ttf", "/usr/share/fonts/font.ttf"},
+		{"DejaVu Sans", "DejaVu Sans"},
+		{"~", home},
+		{"~/fonts/font.ttf", home + "/fonts/font.ttf"},
+		{"/fonts/~/font.ttf", "/fonts/~/font.ttf"},
+		{"~user/font.ttf", "~user/font.ttf"},
+		{"$GOBAR_TEST_DIR/font.ttf", "/tmp/fonts/font.ttf"},
+		{"${GOBAR_TEST_DIR}/font.ttf", "/tmp/fonts/font.ttf"},
+	}
+
+	for i, test := range tests {
+		actual := expandPath(test.input)
+
+		assertEqual(t, test.input, test.output, actual, "ExpandPath", i)
+	}
+}
+
+func TestBarCompose_textOutline(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	tests := []struct {
+		outline *xgraphics.BGRA
+		input   []*TextPiece
+		output  *xgraphics.BGRA
+	}{
+		{nil, []*TextPiece{{Text: "|"}}, nil},
+		{&red, []*TextPiece{{Text: "|"}}, &red},
+		{&red, []*TextPiece{{Text: "|", Outline: &blue}}, &blue},
+		{nil, []*TextPiece{{Text: "|", Outline: &blue}}, &blue},
+	}
+
+	for i, test := range tests {
+		bar := newTestBar(t, &Geometry{Width: 8, Height: 16})
+		bar.Foreground = colors{&white}
+		bar.Options = Options{TextOutline: test.outline}
+		bar.compose(0, bar.resolve(test.input, 1))
+
+		var outlined *xgraphics.BGRA
+		img := bar.canvases[0].img
+		for x := 0; x < 8 && outlined == nil; x++ {
+			for y := 0; y < 16 && outlined == nil; y++ {
+				if c := img.At(x, y).(xgraphics.BGRA); c != black && c != white {
+					outlined = &c
+				}
+			}
+		}
+		assertEqual(t, test.input, test.output, outlined, "BarCompose_textOutline", i)
+	}
+}
+
+func TestBarRequestRedraw(t *testing.T) {
+	bar := &Bar{redraws: make(chan struct{}, 1)}
+
+	tests := []struct {
+		requests int
+		output   int
+	}{
+		{0, 0},
+		{1, 1},
+		{3, 1},
+	}
+
+	for i, test := range tests {
+		for j := 0; j < test.requests; j++ {
+			bar.requestRedraw()
+		}
+
+		assertEqual(t, test.requests, test.output, len(bar.redraws), "BarRequestRedraw", i)
+		for len(bar.redraws) > 0 {
+			<-bar.redraws
+		}
+	}
+}
+
+func TestBarCompose_absX(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	green := xgraphics.BGRA{B: 0x00, G: 0xFF, R: 0x00, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+
+	tests := []struct {
+		input  int
+		output []xgraphics.BGRA
+	}{
+		{16, []xgraphics.BGRA{red, green, blue, black}},
+		{-8, []xgraphics.BGRA{red, green, black, blue}},
+		{0, []xgraphics.BGRA{blue, green, black, black}},
+	}
+
+	for i, test := range tests {
+		x := test.input
+		bar.compose(0, bar.resolve([]*TextPiece{
+			{Text: " ", Background: &red},
+			{Text: " ", Background: &blue, AbsX: &x},
+			{Text: " ", Background: &green},
+		}, 1))
+
+		img := bar.canvases[0].img
+		actual := []xgraphics.BGRA{}
+		for x := 4; x < 32; x += 8 {
+			actual = append(actual, img.At(x, 8).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_absX", i)
+	}
+}
+
+func TestBarCompose_absXPercent(t *testing.T) {
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 64, Height: 16})
+
+	tests := []struct {
+		x      int
+		align  Align
+		output []image.Rectangle
+	}{
+		{25, LEFT, []image.Rectangle{image.Rect(16, 0, 32, 16)}},
+		{-25, LEFT, []image.Rectangle{image.Rect(48, 0, 64, 16)}},
+		{50, CENTER, []image.Rectangle{image.Rect(24, 0, 40, 16)}},
+		{-50, CENTER, []image.Rectangle{image.Rect(24, 0, 40, 16)}},
+	}
+
+	for i, test := range tests {
+		x := test.x
+		bar.compose(0, bar.resolve([]*TextPiece{
+			{Text: "  ", Background: &blue, AbsX: &x, AbsXPercent: true, Align: test.align},
+		}, 1))
+		assertEqual(t, test.x, test.output, bar.canvases[0].spans, "BarCompose_absXPercent", i)
+	}
+}
+
+func TestBarCompose_zIndex(t *testing.T) {
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	// render draws pieces, encoded as PNG.
+	render := func(pieces []*TextPiece) []byte {
+		bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+		bar.compose(0, bar.resolve(pieces, 1))
+		var out bytes.Buffer
+		if err := png.Encode(&out, bar.canvases[0].img); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	tests := []struct {
+		input []*TextPiece
+		// expected draws the same, with pieces already in drawing order.
+		expected []*TextPiece
+	}{
+		// Higher ZIndex is drawn on top, whatever the input order.
+		{
+			[]*TextPiece{
+				{Text: "  ", Background: &red, AbsX: intPtr(0), ZIndex: 1},
+				{Text: " ", Background: &blue, AbsX: intPtr(8)},
+			},
+			[]*TextPiece{
+				{Text: " ", Background: &blue, AbsX: intPtr(8)},
+				{Text: "  ", Background: &red, AbsX: intPtr(0)},
+			},
+		},
+		{
+			[]*TextPiece{
+				{Text: " ", Background: &blue, AbsX: intPtr(8), ZIndex: 5},
+				{Text: "  ", Background: &red, AbsX: intPtr(0), ZIndex: -1},
+			},
+			[]*TextPiece{
+				{Text: "  ", Background: &red, AbsX: intPtr(0)},
+				{Text: " ", Background: &blue, AbsX: intPtr(8)},
+			},
+		},
+		// Equal ZIndex keeps the input order.
+		{
+			[]*TextPiece{
+				{Text: " ", Background: &blue, AbsX: intPtr(8), ZIndex: 2},
+				{Text: "  ", Background: &red, AbsX: intPtr(0), ZIndex: 2},
+			},
+			[]*TextPiece{
+				{Text: " ", Background: &blue, AbsX: intPtr(8)},
+				{Text: "  ", Background: &red, AbsX: intPtr(0)},
+			},
+		},
+		// Absolute pieces are drawn over the flowing ones.
+		{
+			[]*TextPiece{
+				{Text: " ", Background: &blue, AbsX: intPtr(4)},
+				{Text: "  ", Background: &red},
+			},
+			[]*TextPiece{
+				{Text: "  ", Background: &red},
+				{Text: " ", Background: &blue, AbsX: intPtr(4)},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		assertEqual(t, test.input, true, bytes.Equal(render(test.expected), render(test.input)), "BarCompose_zIndex", i)
+	}
+}
+
+func TestBarCompose_gap(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	green := xgraphics.BGRA{B: 0x00, G: 0xFF, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+
+	tests := []struct {
+		input  Align
+		output []xgraphics.BGRA
+	}{
+		{LEFT, []xgraphics.BGRA{red, black, green, black}},
+		{RIGHT, []xgraphics.BGRA{black, green, black, red}},
+	}
+
+	for i, test := range tests {
+		bar.compose(0, bar.resolve([]*TextPiece{
+			{Text: " ", Background: &red, Align: test.input},
+			{Gap: 8, Align: test.input},
+			{Text: " ", Background: &green, Align: test.input},
+		}, 1))
+
+		img := bar.canvases[0].img
+		actual := []xgraphics.BGRA{}
+		for x := 4; x < 32; x += 8 {
+			actual = append(actual, img.At(x, 8).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_gap", i)
+	}
+}
+
+func TestBarCompose_wholeFrame(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	green := xgraphics.BGRA{B: 0x00, G: 0xFF, R: 0x00, A: 0xFF}
+
+	bar := &Bar{
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+	}
+	d := createFake(bar, nil, 32)
+
+	tests := []struct {
+		input  []*TextPiece
+		output []xgraphics.BGRA
+	}{
+		{[]*TextPiece{{Text: "   ", Background: &red}}, []xgraphics.BGRA{red, red, red, black}},
+		// Nothing of the previous frame is left behind.
+		{[]*TextPiece{{Text: " ", Background: &green}}, []xgraphics.BGRA{green, black, black, black}},
+	}
+
+	for i, test := range tests {
+		bar.Draw(test.input)
+
+		win := d.windows[bar.Windows[0].Id]
+		assertEqual(t, test.input, i+1, win.paints, "BarCompose_wholeFrame", i)
+		assertEqual(t, test.input, 0, len(win.rects), "BarCompose_wholeFrame", i)
+
+		actual := []xgraphics.BGRA{}
+		for x := 4; x < 32; x += 8 {
+			actual = append(actual, win.surface.At(x, 8).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_wholeFrame", i)
+	}
+}
+
+func TestBarCompose_center(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	green := xgraphics.BGRA{B: 0x00, G: 0xFF, R: 0x00, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 48, Height: 16})
+
+	tests := []struct {
+		input  []*TextPiece
+		output []xgraphics.BGRA
+	}{
+		{[]*TextPiece{
+			{Text: " ", Background: &red, Align: CENTER},
+			{Text: " ", Background: &green, Align: CENTER},
+		}, []xgraphics.BGRA{black, black, red, green, black, black}},
+		{[]*TextPiece{
+			{Text: " ", Background: &blue},
+			{Text: " ", Background: &red, Align: CENTER},
+			{Text: " ", Background: &blue, Align: RIGHT},
+		}, []xgraphics.BGRA{blue, black, red, black, black, blue}},
+	}
+
+	for i, test := range tests {
+		bar.compose(0, bar.resolve(test.input, 1))
+
+		img := bar.canvases[0].img
+		actual := []xgraphics.BGRA{}
+		for x := 4; x < 48; x += 8 {
+			actual = append(actual, img.At(x, 8).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_center", i)
+	}
+}
+
+func TestBarCompose_leadPad(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 64, Height: 16})
+	bar.Foreground = colors{&white}
+
+	tests := []struct {
+		input  Align
+		output []bool
+	}{
+		{LEFT, []bool{false, true, true, true, true, false, false, false}},
+		{CENTER, []bool{false, false, false, true, true, true, true, false}},
+		{RIGHT, []bool{false, false, false, false, true, true, true, true}},
+	}
+
+	for i, test := range tests {
+		bar.compose(0, bar.resolve([]*TextPiece{{Text: "||||", LeadPad: 8, Align: test.input}}, 1))
+
+		img := bar.canvases[0].img
+		actual := []bool{}
+		for x := 0; x < 64; x += 8 {
+			lit := false
+			for dx := 0; dx < 8; dx++ {
+				lit = lit || img.At(x+dx, 8) != black
+			}
+			actual = append(actual, lit)
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_leadPad", i)
+	}
+}
+
+func TestBarCompose_blockHeight(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 8, Height: 16})
+
+	tests := []struct {
+		input  int
+		output []xgraphics.BGRA
+	}{
+		{0, []xgraphics.BGRA{red, red, red, red}},
+		{8, []xgraphics.BGRA{black, red, red, black}},
+		{32, []xgraphics.BGRA{red, red, red, red}},
+	}
+
+	for i, test := range tests {
+		bar.compose(0, bar.resolve([]*TextPiece{
+			{Text: " ", Background: &red, BlockHeight: test.input},
+		}, 1))
+
+		img := bar.canvases[0].img
+		actual := []xgraphics.BGRA{}
+		for y := 2; y < 16; y += 4 {
+			actual = append(actual, img.At(4, y).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_blockHeight", i)
+	}
+}
+
+func TestBarColumns(t *testing.T) {
+	bar := &Bar{Fonts: fonts{inconsolata.Regular8x16}}
+
+	tests := []struct {
+		input  []*TextPiece
+		output []int
+	}{
+		{[]*TextPiece{{Text: "t"}, {Text: "test"}}, []int{0, 0}},
+		{[]*TextPiece{
+			{Text: "t", Column: intPtr(1)},
+			{Text: "te", Column: intPtr(2)},
+			{Text: "test", Column: intPtr(1), Row: 1},
+			{Text: "t", Column: intPtr(2), Row: 1, MinWidth: 20},
+		}, []int{32, 20, 32, 20}},
+	}
+
+	for i, test := range tests {
+		var actual []int
+		for _, piece := range bar.columns(test.input) {
+			actual = append(actual, piece.MinWidth)
+		}
+		assertEqual(t, test.input, test.output, actual, "BarColumns", i)
+		assertEqual(t, test.input, 0, test.input[0].MinWidth, "BarColumns", i)
+	}
+}
+
+func TestBarSpinners(t *testing.T) {
+	tests := []struct {
+		frame  int
+		output []string
+	}{
+		{0, []string{"test", "|", "."}},
+		{1, []string{"test", "/", "o"}},
+		{5, []string{"test", "/", "O"}},
+	}
+
+	input := []*TextPiece{{Text: "test"}, {Spin: true}, {Text: ".oO", Spin: true}}
+	for i, test := range tests {
+		bar := &Bar{spinFrame: test.frame}
+		var actual []string
+		for _, piece := range bar.spinners(input) {
+			actual = append(actual, piece.Text)
+		}
+		assertEqual(t, test.frame, test.output, actual, "BarSpinners", i)
+		assertEqual(t, test.frame, "", input[1].Text, "BarSpinners", i)
+	}
+}
+
+func TestBarAnimate(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+
+	tests := []struct {
+		input []*TextPiece
+		frame int
+		draws int
+	}{
+		{nil, 0, 0},
+		{[]*TextPiece{{Text: "test"}}, 0, 0},
+		{[]*TextPiece{{Text: "test"}, {Spin: true}}, 1, 1},
+		{[]*TextPiece{{Text: "test", Urgent: true}}, 0, 1},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{
+			Foreground: colors{&black},
+			Background: colors{&black},
+			Fonts:      fonts{inconsolata.Regular8x16},
+			Options:    Options{UrgentColor: &black},
+			lastText:   test.input,
+		}
+		d := createFake(bar, nil, 32)
+
+		bar.animate()
+		assertEqual(t, test.input, test.frame, bar.spinFrame, "BarAnimate", i)
+		assertEqual(t, test.input, test.draws, d.windows[bar.Windows[0].Id].paints, "BarAnimate", i)
+	}
+}
+
+func TestBarCompose_urgent(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+	gray := xgraphics.BGRA{B: 0x40, G: 0x40, R: 0x40, A: 0xFF}
+
+	tests := []struct {
+		period time.Duration
+		pixels []xgraphics.BGRA
+	}{
+		{6 * time.Second, []xgraphics.BGRA{gray, black}},
+		{0, []xgraphics.BGRA{white, black}},
+	}
+
+	defer func(orig func() time.Time) { timeNow = orig }(timeNow)
+	timeNow = func() time.Time { return time.Unix(1, 0) }
+	for i, test := range tests {
+		bar := &Bar{
+			Foreground: colors{&white},
+			Background: colors{&black},
+			Fonts:      fonts{inconsolata.Regular8x16},
+			Options:    Options{UrgentColor: &white, UrgentPeriod: test.period},
+		}
+		createFake(bar, nil, 32)
+		bar.Draw([]*TextPiece{{Text: "  ", Urgent: true}, {Text: "  ", Background: &black}})
+
+		img := bar.canvases[0].img
+		pixels := []xgraphics.BGRA{img.At(4, 8).(xgraphics.BGRA), img.At(20, 8).(xgraphics.BGRA)}
+		assertEqual(t, test.period, test.pixels, pixels, "BarCompose_urgent", i)
+	}
+}
+
+func TestBarCompose_missingGlyphs(t *testing.T) {
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	// Like Face7x13, but without the replacement glyph either,
+	// so that the face has nothing to draw for anything beyond ASCII.
+	asciiOnly := *basicfont.Face7x13
+	asciiOnly.Ranges = asciiOnly.Ranges[:1]
+
+	// render draws text in face, encoded as PNG.
+	render := func(face font.Face, text string) []byte {
+		bar := newTestBar(t, &Geometry{Width: 28, Height: 16})
+		bar.Foreground = colors{&white}
+		bar.Fonts = fonts{face}
+		bar.compose(0, bar.resolve([]*TextPiece{{Text: text}, {Text: "c"}}, 1))
+		var out bytes.Buffer
+		if err := png.Encode(&out, bar.canvases[0].img); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	tests := []struct {
+		face     font.Face
+		text     string
+		expected string
+	}{
+		// Missing whitespace is drawn as a space, not a replacement box.
+		{basicfont.Face7x13, "a\u2003b", "a b"},
+		{basicfont.Face7x13, "a\u00a0b", "a b"},
+		// Whitespace the font has is drawn as usual.
+		{basicfont.Face7x13, "a b", "a b"},
+		// Without glyphs, text that follows is not drawn over.
+		{&asciiOnly, "a\u2003b", "a b"},
+		{&asciiOnly, "a\u00e9b", "a b"},
+	}
+
+	for i, test := range tests {
+		assertEqual(t, test.text, true, bytes.Equal(render(test.face, test.expected), render(test.face, test.text)), "BarCompose_missingGlyphs", i)
+	}
+}
+
+func TestBarCompose_baseline(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	// top returns the first row with any text drawn in it.
+	top := func(img *xgraphics.Image) int {
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				if img.At(x, y).(xgraphics.BGRA) != black {
+					return y
+				}
+			}
+		}
+		return -1
+	}
+
+	tests := []struct {
+		font   uint
+		offset int
+	}{
+		{0, 0},
+		{1, 3},
+		{2, -2},
+		{3, 0},
+	}
+
+	bar := newTestBar(t, &Geometry{Width: 8, Height: 24})
+	bar.Foreground = colors{&white}
+	bar.Fonts = fonts{inconsolata.Regular8x16, inconsolata.Regular8x16, inconsolata.Regular8x16}
+	bar.Options = Options{Baselines: []int{0, 3, -2}}
+	bar.compose(0, bar.resolve([]*TextPiece{{Text: "|"}}, 1))
+	base := top(bar.canvases[0].img)
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	for i, test := range tests {
+		bar.compose(0, bar.resolve([]*TextPiece{{Text: "|", Font: test.font}}, 1))
+		assertEqual(t, test.font, base+test.offset, top(bar.canvases[0].img), "BarCompose_baseline", i)
+	}
+}
+
+func TestBarDraw(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+
+	tests := []struct {
+		input  []*TextPiece
+		output [][]image.Rectangle
+	}{
+		{[]*TextPiece{{Text: "test"}}, [][]image.Rectangle{
+			{image.Rect(0, 0, 32, 16)}, {image.Rect(0, 0, 32, 16)},
+		}},
+		{[]*TextPiece{
+			{Text: "t", Screens: []uint{0}},
+			{Text: "test", Screens: []uint{1}, Align: RIGHT},
+			{Text: "test", Screens: []uint{5}},
+		}, [][]image.Rectangle{
+			{image.Rect(0, 0, 8, 16)}, {image.Rect(32, 0, 64, 16)},
+		}},
+		{[]*TextPiece{{Text: "test", NotScreens: []uint{0}}}, [][]image.Rectangle{
+			{}, {image.Rect(0, 0, 32, 16)},
+		}},
+	}
+
+	for i, test := range tests {
+		bar := &Bar{
+			Foreground: colors{&black},
+			Background: colors{&black},
+			Fonts:      fonts{inconsolata.Regular8x16},
+		}
+		d := createFake(bar, nil, 32, 64)
+
+		bar.Draw(test.input)
+		actual := make([][]image.Rectangle, len(bar.canvases))
+		for screen, canvas := range bar.canvases {
+			assertEqual(t, test.input, 1, d.windows[bar.Windows[screen].Id].paints, "BarDraw", i)
+			actual[screen] = append([]image.Rectangle{}, canvas.spans...)
+		}
+		assertEqual(t, test.input, test.output, actual, "BarDraw", i)
+	}
+}
+
+func TestBarMirrorOf(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	tests := []struct {
+		geometries []*Geometry
+		background colors
+		hovering   int
+		input      []*TextPiece
+		output     []int
+	}{
+		{
+			[]*Geometry{{Width: 32, Height: 16}, {X: 32, Width: 32, Height: 16}, {Width: 64, Height: 16}},
+			colors{&black}, -1, []*TextPiece{{Text: "test"}}, []int{-1, 0, -1},
+		},
+		{
+			[]*Geometry{{Width: 32, Height: 16}, {Width: 32, Height: 16}, {Width: 32, Height: 16}},
+			colors{&black}, -1, []*TextPiece{{Text: "test", Screens: []uint{1, 2}}}, []int{-1, -1, 1},
+		},
+		{
+			[]*Geometry{{Width: 32, Height: 16}, {Width: 32, Height: 16}},
+			colors{&black, &white}, -1, []*TextPiece{{Text: "test"}}, []int{-1, -1},
+		},
+		{
+			[]*Geometry{{Width: 32, Height: 16}, {Width: 32, Height: 16}, {Width: 32, Height: 16}},
+			colors{&black}, 0, []*TextPiece{{Text: "test"}}, []int{-1, -1, 1},
+		},
+	}
+
+	for i, test := range tests {
+		bar := newTestBar(t, test.geometries...)
+		bar.Foreground = colors{&white}
+		bar.Background = test.background
+		if test.hovering != -1 {
+			bar.canvases[test.hovering].hovering = true
+		}
+
+		pieces := bar.resolve(test.input, len(bar.canvases))
+		actual := make([]int, len(bar.canvases))
+		for screen := range bar.canvases {
+			actual[screen] = bar.mirrorOf(uint(screen), pieces)
+		}
+		assertEqual(t, test.input, test.output, actual, "BarMirrorOf", i)
+	}
+}
+
+func TestBarDraw_mirrored(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	bar := &Bar{
+		Foreground: colors{&white},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+	}
+	createFake(bar, nil, 32, 32)
+	input := []*TextPiece{{Text: "test"}}
+	bar.Draw(input)
+
+	first, second := bar.canvases[0], bar.canvases[1]
+	if !bytes.Equal(first.img.Pix, second.img.Pix) {
+		t.Errorf("BarDraw_mirrored expected screens to be drawn the same\n")
+	}
+	assertEqual(t, input, first.spans, second.spans, "BarDraw_mirrored", 0)
+	assertEqual(t, input, first.spanPieces, second.spanPieces, "BarDraw_mirrored", 0)
+}
+
+func TestBarDraw_sharedFace(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+	face, err := parseFontFace(bytes.NewReader(goregular.TTF), 12, fontOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar := &Bar{
+		Foreground: colors{&white},
+		Background: colors{&black},
+		Fonts:      fonts{face},
+	}
+	createFake(bar, nil, 300, 400)
+	input := []*TextPiece{{Text: "Shared glyphs\tof one face"}, {Text: "drawn apart", Align: RIGHT}}
+	bar.Draw(input)
+
+	// Screens composed one by one have to come out the same.
+	pieces := bar.resolve(input, len(bar.canvases))
+	for screen, canvas := range bar.canvases {
+		concurrent := append([]byte{}, canvas.img.Pix...)
+		bar.compose(uint(screen), pieces)
+		if !bytes.Equal(concurrent, canvas.img.Pix) {
+			t.Errorf("BarDraw_sharedFace expected screen %d to be drawn the same as alone\n", screen)
+		}
+	}
+}
+
+func TestBarCompose_subpixel(t *testing.T) {
+	face, err := parseFontFace(bytes.NewReader(goregular.TTF), 11, fontOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar := newTestBar(t, &Geometry{Width: 400, Height: 16})
+	bar.Fonts = fonts{face}
+
+	for i, align := range []Align{LEFT, RIGHT, CENTER} {
+		var text []*TextPiece
+		for j := 0; j < 12; j++ {
+			bg := xgraphics.BGRA{B: uint8(j), G: 0x80, R: 0x80, A: 0xFF}
+			text = append(text, &TextPiece{Text: "il1", Background: &bg, Align: align})
+		}
+		bar.compose(0, bar.resolve(text, 1))
+
+		img := bar.canvases[0].img
+		spans := bar.canvases[0].spans
+		assertEqual(t, align, len(text), len(spans), "BarCompose_subpixel", i)
+		for j, span := range spans {
+			// Backgrounds of adjacent pieces meet without a gap.
+			if j > 0 {
+				prev := spans[j-1]
+				assertEqual(t, align, true, prev.Max.X == span.Min.X || prev.Min.X == span.Max.X, "BarCompose_subpixel", i)
+			}
+			// And neither gets drawn over by the other.
+			for _, x := range []int{span.Min.X, span.Max.X - 1} {
+				assertEqual(t, align, uint8(j), img.At(x, 0).(xgraphics.BGRA).B, "BarCompose_subpixel", i)
+			}
+		}
+	}
+}
+
+func TestBarCompose_edgeBleed(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	green := xgraphics.BGRA{B: 0x00, G: 0xFF, R: 0x00, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	tests := []struct {
+		input  bool
+		output []xgraphics.BGRA
+	}{
+		{false, []xgraphics.BGRA{black, red, green, black, black, blue, black}},
+		{true, []xgraphics.BGRA{red, red, green, black, black, blue, blue}},
+	}
+
+	for i, test := range tests {
+		bar := newTestBar(t, &Geometry{Width: 56, Height: 16})
+		bar.Options = Options{EdgeBleed: test.input}
+		bar.compose(0, bar.resolve([]*TextPiece{
+			{Gap: 8},
+			{Text: " ", Background: &red},
+			{Text: " ", Background: &green},
+			{Gap: 8, Align: RIGHT},
+			{Text: " ", Background: &blue, Align: RIGHT},
+			{Text: " ", Align: RIGHT},
+		}, 1))
+
+		img := bar.canvases[0].img
+		actual := []xgraphics.BGRA{}
+		for x := 4; x < 56; x += 8 {
+			actual = append(actual, img.At(x, 8).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.input, test.output, actual, "BarCompose_edgeBleed", i)
+	}
+}
+
+func TestBarCompose_transparent(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+	transparent := xgraphics.BGRA{}
+
+	bar := newTestBar(t, &Geometry{Width: 64, Height: 16})
+	bar.Foreground = colors{&white}
+	bar.compose(0, bar.resolve([]*TextPiece{
+		{Text: "||", Foreground: &transparent, Underline: true, MinWidth: 24}, {Text: "|"},
+	}, 1))
+
+	img := bar.canvases[0].img
+	for x := 0; x < 24; x++ {
+		for y := 0; y < 16; y++ {
+			if img.At(x, y).(xgraphics.BGRA) != black {
+				t.Errorf("BarCompose_transparent: pixel at %dx%d changed to %v", x, y, img.At(x, y))
+			}
+		}
+	}
+	expected := []image.Rectangle{image.Rect(0, 0, 24, 16), image.Rect(24, 0, 32, 16)}
+	assertEqual(t, nil, expected, bar.canvases[0].spans, "BarCompose_transparent", 0)
+	assertEqual(t, nil, white, img.At(28, 8).(xgraphics.BGRA), "BarCompose_transparent", 0)
+}
+
+func TestBarCompose_graph(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+	bar.Foreground = colors{&white}
+	bar.compose(0, bar.resolve([]*TextPiece{
+		{Graph: []float64{0, 1, 2}, GraphWidth: 6, GraphHeight: 8}, {Text: "|"},
+	}, 1))
+
+	img := bar.canvases[0].img
+	tests := []struct {
+		pt    image.Point
+		color xgraphics.BGRA
+	}{
+		{image.Pt(1, 11), black},
+		{image.Pt(3, 11), white},
+		{image.Pt(3, 8), white},
+		{image.Pt(3, 7), black},
+		{image.Pt(5, 4), white},
+		{image.Pt(5, 3), black},
+		{image.Pt(5, 12), black},
+	}
+	for i, test := range tests {
+		assertEqual(t, test.pt, test.color, img.At(test.pt.X, test.pt.Y).(xgraphics.BGRA), "BarCompose_graph", i)
+	}
+	expected := []image.Rectangle{image.Rect(0, 0, 6, 16), image.Rect(6, 0, 14, 16)}
+	assertEqual(t, nil, expected, bar.canvases[0].spans, "BarCompose_graph", 0)
+}
+
+func TestBarCompose_rotate(t *testing.T) {
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+
+	// compose draws L rotated, returning canvas of the bar.
+	compose := func(rotate int) *canvas {
+		bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+		bar.Foreground = colors{&white}
+		bar.compose(0, bar.resolve([]*TextPiece{{Text: "L", Rotate: rotate}}, 1))
+		return bar.canvases[0]
+	}
+	upright := compose(0).img
+
+	tests := []struct {
+		rotate int
+		at     func(x, y int) image.Point
+		span   image.Rectangle
+	}{
+		{90, func(x, y int) image.Point { return image.Pt(15-y, 4+x) }, image.Rect(0, 0, 16, 16)},
+		{180, func(x, y int) image.Point { return image.Pt(7-x, 15-y) }, image.Rect(0, 0, 8, 16)},
+		{270, func(x, y int) image.Point { return image.Pt(y, 11-x) }, image.Rect(0, 0, 16, 16)},
+	}
+
+	for i, test := range tests {
+		c := compose(test.rotate)
+		for x := 0; x < 8; x++ {
+			for y := 0; y < 16; y++ {
+				p := test.at(x, y)
+				if c.img.At(p.X, p.Y) != upright.At(x, y) {
+					t.Errorf("BarCompose_rotate:%d pixel at %v differs from upright one at %dx%d\n", i, p, x, y)
+				}
+			}
+		}
+		assertEqual(t, test.rotate, []image.Rectangle{test.span}, c.spans, "BarCompose_rotate", i)
+	}
+}
+
+func TestBarCompose_box(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+
+	bar := newTestBar(t, &Geometry{Width: 32, Height: 16})
+	bar.compose(0, bar.resolve([]*TextPiece{
+		{Text: "  ", Background: &red, BoxBorder: 2, BlockHeight: 12},
+	}, 1))
+
+	img := bar.canvases[0].img
+	tests := []struct {
+		pt    image.Point
+		color xgraphics.BGRA
+	}{
+		{image.Pt(0, 1), black},
+		{image.Pt(0, 2), red},
+		{image.Pt(1, 8), red},
+		{image.Pt(2, 8), black},
+		{image.Pt(8, 3), red},
+		{image.Pt(8, 4), black},
+		{image.Pt(8, 13), red},
+		{image.Pt(14, 8), red},
+		{image.Pt(16, 8), black},
+	}
+	for i, test := range tests {
+		assertEqual(t, test.pt, test.color, img.At(test.pt.X, test.pt.Y).(xgraphics.BGRA), "BarCompose_box", i)
+	}
+}
+
+func TestBarCompose_accentLine(t *testing.T) {
+	red := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	blue := xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}
+
+	tests := []struct {
+		position Position
+		line     AccentLine
+		output   []xgraphics.BGRA
+	}{
+		{TOP, AccentLine{}, []xgraphics.BGRA{blue, blue, blue, blue}},
+		{TOP, AccentLine{&red, 2}, []xgraphics.BGRA{blue, blue, red, red}},
+		{BOTTOM, AccentLine{&red, 2}, []xgraphics.BGRA{red, red, blue, blue}},
+		{BOTTOM, AccentLine{&red, 1}, []xgraphics.BGRA{red, blue, blue, blue}},
+	}
+
+	for i, test := range tests {
+		bar := newTestBar(t, &Geometry{Width: 8, Height: 16})
+		bar.Options = Options{AccentLine: test.line}
+		bar.position = test.position
+		// Piece backgrounds do not break the line.
+		bar.compose(0, bar.resolve([]*TextPiece{{Text: " ", Background: &blue}}, 1))
+
+		img := bar.canvases[0].img
+		actual := []xgraphics.BGRA{}
+		for _, y := range []int{0, 1, 14, 15} {
+			actual = append(actual, img.At(4, y).(xgraphics.BGRA))
+		}
+		assertEqual(t, test.position, test.output, actual, "BarCompose_accentLine", i)
+	}
+}
+
+func TestBarCompose_noAntialias(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+	face, err := parseFontFace(bytes.NewReader(goregular.TTF), 12, fontOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input   bool
+		partial bool
+	}{
+		{false, true},
+		{true, false},
+	}
+
+	for i, test := range tests {
+		bar := newTestBar(t, &Geometry{Width: 64, Height: 16})
+		bar.Foreground = colors{&white}
+		bar.Fonts = fonts{face}
+		bar.Options = Options{NoAntialias: test.input}
+		bar.compose(0, bar.resolve([]*TextPiece{{Text: "gobar"}}, 1))
+
+		covered, partial := 0, 0
+		img := bar.canvases[0].img
+		for x := 0; x < 64; x++ {
+			for y := 0; y < 16; y++ {
+				switch img.At(x, y) {
+				case black:
+				case white:
+					covered++
+				default:
+					partial++
+				}
+			}
+		}
+		assertEqual(t, test.input, true, covered > 0, "BarCompose_noAntialias", i)
+		assertEqual(t, test.input, test.partial, partial > 0, "BarCompose_noAntialias", i)
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	tests := []struct {
+		input   string
+		delim   string
+		records []string
+	}{
+		{"test1\ntest2\n", "\n", []string{"test1\n", "test2\n"}},
+		{"test1\ntest2\x00test3\x00", "\x00", []string{"test1\ntest2\x00", "test3\x00"}},
+		{"te-st1--test2--", "--", []string{"te-st1--", "test2--"}},
+		{"test1--test2", "--", []string{"test1--", "test2"}},
+	}
+
+	for i, test := range tests {
+		reader := bufio.NewReader(strings.NewReader(test.input))
+		var records []string
+		for {
+			record, err := readRecord(reader, test.delim)
+			if record != "" {
+				records = append(records, record)
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		assertEqual(t, test.input, test.records, records, "ReadRecord", i)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	tests := []struct {
+		input  string
+		delim  string
+		rows   int
+		output [][]*TextPiece
+	}{
+		{"test1\ntest2\n", "\n", 1, [][]*TextPiece{{{Text: "test1"}}, {{Text: "test2"}}}},
+		{"test1\ntest2\ntest3\n", "\n", 2, [][]*TextPiece{
+			{{Text: "test1"}},
+			{{Text: "test1"}, {Text: "test2", Row: 1}},
+			{{Text: "test2"}, {Text: "test3", Row: 1}},
+		}},
+		{"test1\ntest2\x00", "\x00", 2, [][]*TextPiece{{{Text: "test1"}, {Text: "test2", Row: 1}}}},
+		{"test1\ntest2\n\x00test3\x00", "\x00", 1, [][]*TextPiece{{{Text: "test1 test2"}}, {{Text: "test3"}}}},
+	}
+
+	for i, test := range tests {
+		texts := make(chan []*TextPiece, len(test.output)+1)
+		parser := NewTextParser()
+		parser.MultiLine = true
+		err := readInput(bufio.NewReader(strings.NewReader(test.input)), test.delim, test.rows, parser, texts)
+		close(texts)
+		assertEqualError(t, io.EOF, err, "ReadInput", i)
+
+		var actual [][]*TextPiece
+		for text := range texts {
+			actual = append(actual, text)
+		}
+		assertEqual(t, test.input, test.output, actual, "ReadInput", i)
+	}
+}
+
+func TestReadUntilEOF(t *testing.T) {
+	tests := []struct {
+		errs  []error
+		calls int
+	}{
+		{[]error{io.EOF}, 1},
+		{[]error{nil}, 1},
+		{[]error{errors.New("test"), errors.New("test"), io.EOF}, 3},
+		{[]error{errors.New("test"), fmt.Errorf("wrapped: %w", io.EOF)}, 2},
+	}
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+
+	for i, test := range tests {
+		texts := make(chan []*TextPiece)
+		calls := 0
+		readUntilEOF(func() error {
+			calls++
+			return test.errs[calls-1]
+		}, texts)
+
+		_, ok := <-texts
+		assertEqual(t, test.errs, false, ok, "ReadUntilEOF", i)
+		assertEqual(t, test.errs, test.calls, calls, "ReadUntilEOF", i)
+	}
+	log.SetOutput(os.Stderr)
+}
+
+func TestLatest(t *testing.T) {
+	text1 := []*TextPiece{{Text: "test1"}}
+	text2 := []*TextPiece{{Text: "test2"}}
+	text3 := []*TextPiece{{Text: "test3"}}
+
+	tests := []struct {
+		waiting [][]*TextPiece
+		closed  bool
+		output  []*TextPiece
+	}{
+		{nil, false, text1},
+		{[][]*TextPiece{text2}, false, text2},
+		{[][]*TextPiece{text2, text3}, false, text3},
+		{[][]*TextPiece{text2}, true, text2},
+	}
+
+	for i, test := range tests {
+		texts := make(chan []*TextPiece, len(test.waiting))
+		for _, text := range test.waiting {
+			texts <- text
+		}
+		if test.closed {
+			close(texts)
+		}
+
+		actual := latest(texts, text1)
+
+		assertEqual(t, len(test.waiting), test.output, actual, "Latest", i)
+		assertEqual(t, len(test.waiting), 0, len(texts), "Latest", i)
+	}
+}
@@ -0,0 +1,267 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+	"time"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/ewmh"
+	"github.com/jezek/xgbutil/xevent"
+	"github.com/jezek/xgbutil/xinerama"
+	"github.com/jezek/xgbutil/xprop"
+	"github.com/jezek/xgbutil/xwindow"
+)
+
+// autoHideInterval is how often pointer position is checked in autohide mode.
+// Pointer is polled instead of listening for MotionNotify on the root window,
+// because these are not reported while pointer is over other windows.
+const autoHideInterval = 100 * time.Millisecond
+
+// barStruts stores struts reserved by a single bar window.
+type barStruts struct {
+	partial *ewmh.WmStrutPartial
+	strut   *ewmh.WmStrut
+}
+
+// setStruts sets struts for the i-th window, unless it is hidden,
+// in which case all the space is released.
+// Embedding window is left alone, it is not a dock,
+// and neither are windows created with NoEWMH.
+func (b *Bar) setStruts(i int) {
+	if b.Window != 0 || b.NoEWMH {
+		return
+	}
+	struts := b.struts[i]
+	if b.hiddenWindows[i] {
+		struts = barStruts{&ewmh.WmStrutPartial{}, &ewmh.WmStrut{}}
+	}
+	b.drawer.setStruts(b.Windows[i].Id, struts)
+}
+
+// windowHidden checks whether the i-th window should be hidden,
+// according to current toggle, autohide and fullscreen state.
+// Embedding window is never hidden, it is mapped by its owner.
+func (b *Bar) windowHidden(i int) bool {
+	if b.Window != 0 {
+		return false
+	}
+	return b.toggled || b.autoHidden || b.rects[i].Overlaps(b.fullscreen)
+}
+
+// toggle hides the bar if it is visible and shows it back otherwise.
+// Bar hidden that way stays hidden even when autohide would show it.
+func (b *Bar) toggle() {
+	b.toggled = !b.toggled
+	b.updateHidden()
+}
+
+// updateHidden maps or unmaps windows, reserving or releasing
+// their struts, whenever their hidden state changes.
+func (b *Bar) updateHidden() {
+	for i, win := range b.Windows {
+		hidden := b.windowHidden(i)
+		if hidden == b.hiddenWindows[i] {
+			continue
+		}
+		b.hiddenWindows[i] = hidden
+		if hidden {
+			b.drawer.unmapWindow(win.Id)
+		}
+		b.setStruts(i)
+		if !hidden {
+			b.drawer.mapWindow(win.Id)
+		}
+	}
+}
+
+// autoHide shows or hides the bar, depending on where the pointer is.
+func (b *Bar) autoHide(now time.Time) {
+	pointer, err := xproto.QueryPointer(b.X.Conn(), b.X.RootWin()).Reply()
+	if err != nil {
+		logf(ERROR, "Error `%s` querying pointer position", err)
+		return
+	}
+	p := image.Pt(int(pointer.RootX), int(pointer.RootY))
+
+	if pointerNearBar(p, b.heads, b.rects, b.toggled || b.autoHidden, b.position) {
+		b.lastNear = now
+		b.autoHidden = false
+	} else if now.Sub(b.lastNear) >= b.AutoHideDelay {
+		b.autoHidden = true
+	}
+	b.updateHidden()
+}
+
+// pointerNearBar checks whether pointer is at the edge of any head
+// the bar is docked at or, if the bar is not hidden, over any of its rects.
+func pointerNearBar(
+	p image.Point, heads xinerama.Heads, rects []image.Rectangle,
+	hidden bool, position Position,
+) bool {
+	if !hidden {
+		for _, rect := range rects {
+			if p.In(rect) {
+				return true
+			}
+		}
+	}
+	for _, head := range heads {
+		if p.X < head.X() || p.X >= head.X()+head.Width() {
+			continue
+		}
+		if position == TOP && p.Y == head.Y() {
+			return true
+		}
+		if position == BOTTOM && p.Y == head.Y()+head.Height()-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// watchFullscreen starts tracking active window, to hide the bar from
+// the head it is on whenever it goes fullscreen.
+func (b *Bar) watchFullscreen() {
+	var active xproto.Window
+	update := func() {
+		b.fullscreen = image.Rectangle{}
+		if active != 0 && windowFullscreen(b.X, active) {
+			geom, err := xwindow.New(b.X, active).DecorGeometry()
+			if err == nil {
+				b.fullscreen = headOf(b.heads, image.Rect(
+					geom.X(), geom.Y(), geom.X()+geom.Width(), geom.Y()+geom.Height(),
+				))
+			}
+		}
+		b.updateHidden()
+	}
+
+	watch := &stateWatch{update}
+	xevent.PropertyNotifyFun(func(X *xgbutil.XUtil, ev xevent.PropertyNotifyEvent) {
+		name, err := xprop.AtomName(X, ev.Atom)
+		if err != nil || name != "_NET_ACTIVE_WINDOW" {
+			return
+		}
+		newActive, err := ewmh.ActiveWindowGet(X)
+		if err != nil || newActive == active {
+			return
+		}
+		if active != 0 {
+			watch.disconnect(X, active)
+		}
+		active = newActive
+		// Bar windows never go fullscreen, and listening on them
+		// would replace events they are listening for.
+		if active != 0 && !b.ownWindow(active) {
+			// Fullscreen state can change without changing active window.
+			win := xwindow.New(X, active)
+			win.Listen(xproto.EventMaskPropertyChange)
+			watch.Connect(X, active)
+		}
+		update()
+	}).Connect(b.X, b.X.RootWin())
+}
+
+// ownWindow checks whether win is one of the bar windows.
+func (b *Bar) ownWindow(win xproto.Window) bool {
+	for _, window := range b.Windows {
+		if window.Id == win {
+			return true
+		}
+	}
+	return false
+}
+
+// stateWatch calls update whenever _NET_WM_STATE of the window
+// it is connected to changes. Unlike xevent.PropertyNotifyFun, it can
+// be disconnected alone, leaving other callbacks of the window in place.
+type stateWatch struct {
+	update func()
+}
+
+func (w *stateWatch) Connect(X *xgbutil.XUtil, win xproto.Window) {
+	X.CallbacksLck.Lock()
+	defer X.CallbacksLck.Unlock()
+	if X.Callbacks[xevent.PropertyNotify] == nil {
+		X.Callbacks[xevent.PropertyNotify] = map[xproto.Window][]xgbutil.Callback{}
+	}
+	// Callbacks are copied on write, as xevent runs them without a lock.
+	callbacks := X.Callbacks[xevent.PropertyNotify][win]
+	X.Callbacks[xevent.PropertyNotify][win] = append(callbacks[:len(callbacks):len(callbacks)], w)
+}
+
+func (w *stateWatch) Run(X *xgbutil.XUtil, event interface{}) {
+	ev := event.(xevent.PropertyNotifyEvent)
+	if name, err := xprop.AtomName(X, ev.Atom); err == nil && name == "_NET_WM_STATE" {
+		w.update()
+	}
+}
+
+// disconnect removes the watch from callbacks of win.
+func (w *stateWatch) disconnect(X *xgbutil.XUtil, win xproto.Window) {
+	X.CallbacksLck.Lock()
+	defer X.CallbacksLck.Unlock()
+	var kept []xgbutil.Callback
+	for _, callback := range X.Callbacks[xevent.PropertyNotify][win] {
+		// Callbacks of other types compare unequal, without panicking
+		// on function types that cannot be compared.
+		if callback != xgbutil.Callback(w) {
+			kept = append(kept, callback)
+		}
+	}
+	if len(kept) == 0 {
+		delete(X.Callbacks[xevent.PropertyNotify], win)
+	} else {
+		X.Callbacks[xevent.PropertyNotify][win] = kept
+	}
+}
+
+// windowFullscreen checks whether window has fullscreen EWMH state.
+func windowFullscreen(X *xgbutil.XUtil, win xproto.Window) bool {
+	states, err := ewmh.WmStateGet(X, win)
+	if err != nil {
+		return false
+	}
+	for _, state := range states {
+		if state == "_NET_WM_STATE_FULLSCREEN" {
+			return true
+		}
+	}
+	return false
+}
+
+// headOf returns rect of the head containing center of given rect,
+// or an empty one if there is no such head.
+func headOf(heads xinerama.Heads, rect image.Rectangle) image.Rectangle {
+	center := rect.Min.Add(rect.Max).Div(2)
+	for _, head := range heads {
+		r := image.Rect(head.X(), head.Y(), head.X()+head.Width(), head.Y()+head.Height())
+		if center.In(r) {
+			return r
+		}
+	}
+	return image.Rectangle{}
+}
@@ -0,0 +1,121 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+	"sync"
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/xevent"
+	"github.com/jezek/xgbutil/xinerama"
+	"github.com/jezek/xgbutil/xrect"
+)
+
+func TestPointerNearBar(t *testing.T) {
+	heads := xinerama.Heads{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 0, 1280, 1024),
+	}
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 1920, 16),
+		image.Rect(1920, 0, 3200, 16),
+	}
+	bottomRects := []image.Rectangle{
+		image.Rect(0, 1064, 1920, 1080),
+		image.Rect(1920, 1008, 3200, 1024),
+	}
+
+	tests := []struct {
+		p        image.Point
+		rects    []image.Rectangle
+		hidden   bool
+		position Position
+		near     bool
+	}{
+		{image.Pt(100, 0), rects, true, TOP, true},
+		{image.Pt(100, 5), rects, true, TOP, false},
+		{image.Pt(100, 5), rects, false, TOP, true},
+		{image.Pt(100, 16), rects, false, TOP, false},
+		{image.Pt(2000, 0), rects, true, TOP, true},
+		{image.Pt(100, 1079), rects, true, TOP, false},
+		{image.Pt(100, 1079), bottomRects, true, BOTTOM, true},
+		{image.Pt(2000, 1079), bottomRects, true, BOTTOM, false},
+		{image.Pt(2000, 1023), bottomRects, true, BOTTOM, true},
+		{image.Pt(2000, 1010), bottomRects, false, BOTTOM, true},
+		{image.Pt(2000, 1010), bottomRects, true, BOTTOM, false},
+	}
+
+	for i, test := range tests {
+		near := pointerNearBar(test.p, heads, test.rects, test.hidden, test.position)
+
+		assertEqual(t, test.p, test.near, near, "PointerNearBar", i)
+	}
+}
+
+func TestHeadOf(t *testing.T) {
+	heads := xinerama.Heads{
+		xrect.New(0, 0, 1920, 1080),
+		xrect.New(1920, 0, 1280, 1024),
+	}
+
+	tests := []struct {
+		rect image.Rectangle
+		head image.Rectangle
+	}{
+		{image.Rect(0, 0, 1920, 1080), image.Rect(0, 0, 1920, 1080)},
+		{image.Rect(1920, 0, 3200, 1024), image.Rect(1920, 0, 3200, 1024)},
+		{image.Rect(1800, 0, 3200, 1024), image.Rect(1920, 0, 3200, 1024)},
+		{image.Rect(4000, 0, 5000, 1024), image.Rectangle{}},
+	}
+
+	for i, test := range tests {
+		head := headOf(heads, test.rect)
+
+		assertEqual(t, test.rect, test.head, head, "HeadOf", i)
+	}
+}
+
+func TestStateWatchDisconnect(t *testing.T) {
+	X := &xgbutil.XUtil{
+		Callbacks:    map[int]map[xproto.Window][]xgbutil.Callback{},
+		CallbacksLck: &sync.RWMutex{},
+	}
+	var win xproto.Window = 1
+	xevent.PropertyNotifyFun(func(*xgbutil.XUtil, xevent.PropertyNotifyEvent) {}).Connect(X, win)
+	xevent.ButtonPressFun(func(*xgbutil.XUtil, xevent.ButtonPressEvent) {}).Connect(X, win)
+	watch, other := &stateWatch{}, &stateWatch{}
+	watch.Connect(X, win)
+	other.Connect(X, win)
+
+	watch.disconnect(X, win)
+	callbacks := X.Callbacks[xevent.PropertyNotify][win]
+	assertEqual(t, win, 2, len(callbacks), "StateWatchDisconnect", 0)
+	assertEqual(t, win, true, callbacks[1] == xgbutil.Callback(other), "StateWatchDisconnect", 0)
+	assertEqual(t, win, 1, len(X.Callbacks[xevent.ButtonPress][win]), "StateWatchDisconnect", 0)
+
+	other.disconnect(X, win)
+	assertEqual(t, win, 1, len(X.Callbacks[xevent.PropertyNotify][win]), "StateWatchDisconnect", 1)
+}
@@ -0,0 +1,151 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/jezek/xgb/shape"
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/xevent"
+	"github.com/jezek/xgbutil/xgraphics"
+)
+
+// addSpan records that piece was drawn over rect.
+func (c *canvas) addSpan(rect image.Rectangle, piece *TextPiece) {
+	c.spans = append(c.spans, rect)
+	c.spanPieces = append(c.spanPieces, piece)
+}
+
+// spanAt returns index of the drawn span containing pt,
+// or -1 if pt is not over any of them. Of overlapping spans,
+// the one drawn last, so on top, is returned.
+func (c *canvas) spanAt(pt image.Point) int {
+	for i := len(c.spans) - 1; i >= 0; i-- {
+		if pt.In(c.spans[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hovered returns index of the drawn span pointer is over, or -1.
+func (c *canvas) hovered() int {
+	if !c.hovering {
+		return -1
+	}
+	return c.spanAt(c.pointer)
+}
+
+// highlightHovered blends HoverHighlight over the span of given screen
+// that pointer is over, if any.
+func (b *Bar) highlightHovered(screen uint) {
+	c := b.canvases[screen]
+	i := c.hovered()
+	if b.HoverHighlight == nil || i == -1 {
+		return
+	}
+	subimg, ok := c.img.SubImage(c.spans[i]).(*xgraphics.Image)
+	if !ok || subimg == nil {
+		return
+	}
+	subimg.For(func(x, y int) xgraphics.BGRA {
+		return xgraphics.BlendBGRA(subimg.At(x, y).(xgraphics.BGRA), *b.HoverHighlight)
+	})
+}
+
+// hover moves pointer of given screen to pt, or out of the screen,
+// if hovering is false. If that changes the span being hovered over,
+// the screen is composed again. Returns spans that need repainting.
+func (b *Bar) hover(screen uint, pt image.Point, hovering bool) []image.Rectangle {
+	c := b.canvases[screen]
+	before := c.hovered()
+	c.pointer, c.hovering = pt, hovering
+	after := c.hovered()
+	if before == after || b.lastPieces == nil {
+		return nil
+	}
+	name := ""
+	if after != -1 {
+		name = c.spanPieces[after].Name
+	}
+	b.reportHover(name)
+	if b.HoverHighlight == nil {
+		return nil
+	}
+
+	var rects []image.Rectangle
+	if before != -1 {
+		rects = append(rects, c.spans[before])
+	}
+	b.compose(screen, b.lastPieces)
+	if after != -1 {
+		rects = append(rects, c.spans[after])
+	}
+	return rects
+}
+
+// reportHover writes name to HoverReport, if it differs from
+// the one written before.
+func (b *Bar) reportHover(name string) {
+	if b.HoverReport == nil || name == b.hoveredName {
+		return
+	}
+	b.hoveredName = name
+	if _, err := fmt.Fprintln(b.HoverReport, name); err != nil {
+		logEvery(hotLogInterval, ERROR, "Could not report hovered piece: %s", err)
+	}
+}
+
+// listenHover makes window of given screen highlight pieces
+// pointer moves over, and report their names.
+// Window must already listen to windowEvents.
+func (b *Bar) listenHover(screen int) {
+	win := b.Windows[screen]
+
+	paint := func(rects []image.Rectangle) {
+		if len(rects) > 0 {
+			b.drawer.paintRects(b.canvases[screen].img, win.Id, rects)
+		}
+	}
+	xevent.MotionNotifyFun(func(_ *xgbutil.XUtil, ev xevent.MotionNotifyEvent) {
+		paint(b.hover(uint(screen), image.Pt(int(ev.EventX), int(ev.EventY)), true))
+	}).Connect(b.X, win.Id)
+	xevent.LeaveNotifyFun(func(_ *xgbutil.XUtil, _ xevent.LeaveNotifyEvent) {
+		paint(b.hover(uint(screen), image.Point{}, false))
+	}).Connect(b.X, win.Id)
+}
+
+// clickThrough makes pointer events pass through window
+// to windows beneath it, by giving it an empty input shape.
+func clickThrough(X *xgbutil.XUtil, win xproto.Window) error {
+	if err := shape.Init(X.Conn()); err != nil {
+		return err
+	}
+	return shape.RectanglesChecked(
+		X.Conn(), shape.SoSet, shape.SkInput, xproto.ClipOrderingUnsorted,
+		win, 0, 0, nil,
+	).Check()
+}
@@ -0,0 +1,155 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/jezek/xgbutil"
+	"github.com/jezek/xgbutil/xgraphics"
+	"golang.org/x/image/font/inconsolata"
+)
+
+func TestCanvasSpanAt(t *testing.T) {
+	c := &canvas{spans: []image.Rectangle{
+		image.Rect(0, 0, 8, 16), image.Rect(8, 0, 24, 16), image.Rect(40, 0, 48, 16),
+		// Drawn over the previous one.
+		image.Rect(36, 0, 44, 16),
+	}}
+
+	tests := []struct {
+		input  image.Point
+		output int
+	}{
+		{image.Pt(0, 0), 0},
+		{image.Pt(7, 15), 0},
+		{image.Pt(8, 8), 1},
+		{image.Pt(30, 8), -1},
+		{image.Pt(47, 8), 2},
+		{image.Pt(48, 8), -1},
+		{image.Pt(4, 16), -1},
+		{image.Pt(42, 8), 3},
+		{image.Pt(37, 8), 3},
+	}
+
+	for i, test := range tests {
+		actual := c.spanAt(test.input)
+
+		assertEqual(t, test.input, test.output, actual, "CanvasSpanAt", i)
+	}
+}
+
+func TestBarHover(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	white := xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0xFF}
+	gray := xgraphics.BGRA{B: 0x80, G: 0x80, R: 0x80, A: 0xFF}
+
+	bar := &Bar{
+		X:          &xgbutil.XUtil{},
+		Geometries: []*Geometry{{Width: 48, Height: 16}},
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+		Options:    Options{HoverHighlight: &xgraphics.BGRA{B: 0xFF, G: 0xFF, R: 0xFF, A: 0x80}},
+	}
+	bar.createCanvases()
+
+	// Nothing drawn yet, so nothing to highlight.
+	assertEqual(t, "empty", []image.Rectangle(nil), bar.hover(0, image.Pt(4, 8), true), "BarHover", 0)
+
+	bar.lastPieces = bar.resolve([]*TextPiece{
+		{Text: " "}, {Text: "  ", Background: &white}, {Text: " ", Align: RIGHT},
+	}, 1)
+	// Start from pointer outside of the bar.
+	bar.canvases[0].hovering = false
+	bar.compose(0, bar.lastPieces)
+
+	tests := []struct {
+		input    image.Point
+		hovering bool
+		output   []image.Rectangle
+		pixels   []xgraphics.BGRA
+	}{
+		{image.Pt(4, 8), true, []image.Rectangle{image.Rect(0, 0, 8, 16)}, []xgraphics.BGRA{gray, white, black, black}},
+		{image.Pt(6, 2), true, nil, []xgraphics.BGRA{gray, white, black, black}},
+		{image.Pt(12, 8), true, []image.Rectangle{image.Rect(0, 0, 8, 16), image.Rect(8, 0, 24, 16)}, []xgraphics.BGRA{black, white, black, black}},
+		{image.Pt(30, 8), true, []image.Rectangle{image.Rect(8, 0, 24, 16)}, []xgraphics.BGRA{black, white, black, black}},
+		{image.Pt(44, 8), true, []image.Rectangle{image.Rect(40, 0, 48, 16)}, []xgraphics.BGRA{black, white, black, gray}},
+		{image.Point{}, false, []image.Rectangle{image.Rect(40, 0, 48, 16)}, []xgraphics.BGRA{black, white, black, black}},
+	}
+
+	for i, test := range tests {
+		actual := bar.hover(0, test.input, test.hovering)
+
+		assertEqual(t, test.input, test.output, actual, "BarHover", i)
+		img := bar.canvases[0].img
+		pixels := []xgraphics.BGRA{
+			img.At(4, 8).(xgraphics.BGRA), img.At(12, 8).(xgraphics.BGRA),
+			img.At(30, 8).(xgraphics.BGRA), img.At(44, 8).(xgraphics.BGRA),
+		}
+		assertEqual(t, test.input, test.pixels, pixels, "BarHover", i)
+	}
+}
+
+func TestBarReportHover(t *testing.T) {
+	black := xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+
+	var report strings.Builder
+	bar := &Bar{
+		X:          &xgbutil.XUtil{},
+		Geometries: []*Geometry{{Width: 48, Height: 16}},
+		Foreground: colors{&black},
+		Background: colors{&black},
+		Fonts:      fonts{inconsolata.Regular8x16},
+		Options:    Options{HoverReport: &report},
+	}
+	bar.createCanvases()
+	bar.lastPieces = bar.resolve([]*TextPiece{
+		{Text: " ", Name: "cpu"}, {Text: " ", Name: "cpu"}, {Text: " "}, {Text: " ", Name: "clock"},
+	}, 1)
+	bar.compose(0, bar.lastPieces)
+
+	tests := []struct {
+		input    image.Point
+		hovering bool
+		output   string
+	}{
+		{image.Pt(4, 8), true, "cpu\n"},
+		{image.Pt(12, 8), true, ""},
+		{image.Pt(20, 8), true, "\n"},
+		{image.Pt(28, 8), true, "clock\n"},
+		{image.Pt(44, 8), true, "\n"},
+		{image.Pt(28, 8), true, "clock\n"},
+		{image.Point{}, false, "\n"},
+	}
+
+	for i, test := range tests {
+		report.Reset()
+		rects := bar.hover(0, test.input, test.hovering)
+
+		assertEqual(t, test.input, []image.Rectangle(nil), rects, "BarReportHover", i)
+		assertEqual(t, test.input, test.output, report.String(), "BarReportHover", i)
+	}
+}
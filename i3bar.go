@@ -0,0 +1,214 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// i3barSeparatorWidth is a gap between i3bar blocks,
+// unless they set their own.
+const i3barSeparatorWidth = 9
+
+// i3barBlock is a single block of i3bar protocol status line.
+type i3barBlock struct {
+	FullText            string      `json:"full_text"`
+	Color               string      `json:"color"`
+	Background          string      `json:"background"`
+	MinWidth            interface{} `json:"min_width"`
+	Urgent              bool        `json:"urgent"`
+	Name                string      `json:"name"`
+	Instance            string      `json:"instance"`
+	SeparatorBlockWidth *int        `json:"separator_block_width"`
+	Markup              string      `json:"markup"`
+}
+
+// I3barParser reads status lines written in i3bar JSON protocol,
+// one array of blocks per line, drawing them aligned to the right.
+// Header and lines opening or closing the endless array keep
+// the previous status line.
+type I3barParser struct {
+	mu   sync.Mutex
+	last []*TextPiece
+}
+
+// trimI3barLine returns line without surrounding space and commas
+// separating status lines of the endless array, and without
+// the array opening, if the first status line follows it right away.
+func trimI3barLine(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSpace(strings.TrimPrefix(line, ","))
+	line = strings.TrimSpace(strings.TrimSuffix(line, ","))
+	if rest := strings.TrimSpace(strings.TrimPrefix(line, "[")); strings.HasPrefix(rest, "[") {
+		return rest
+	}
+	return line
+}
+
+// isI3barLine tells whether line is a part of i3bar protocol,
+// rather than gobar markup.
+func isI3barLine(line string) bool {
+	line = trimI3barLine(line)
+	switch {
+	case line == "[" || line == "]":
+		return true
+	case strings.HasPrefix(line, "{"):
+		var header struct {
+			Version *int `json:"version"`
+		}
+		return json.Unmarshal([]byte(line), &header) == nil && header.Version != nil
+	case strings.HasPrefix(line, "["):
+		return json.Valid([]byte(line))
+	}
+	return false
+}
+
+// Scan scans i3bar status line and returns array of TextPieces.
+func (ip *I3barParser) Scan(r io.Reader) []*TextPiece {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logEvery(hotLogInterval, WARN, "Problem reading i3bar status line: %s", err)
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	line := trimI3barLine(string(data))
+	if !strings.HasPrefix(line, "[") || line == "[" {
+		return ip.last
+	}
+	var blocks []i3barBlock
+	if err := json.Unmarshal([]byte(line), &blocks); err != nil {
+		stats.parseErrors.Add(1)
+		logEvery(hotLogInterval, WARN, "Problem parsing i3bar status line: %s", err)
+		return ip.last
+	}
+
+	// Blocks are collected left to right, then reversed,
+	// as right aligned pieces are drawn from the right edge.
+	var pieces []*TextPiece
+	for i, block := range blocks {
+		pieces = append(pieces, i3barPieces(block)...)
+		if i == len(blocks)-1 {
+			break
+		}
+		gap := i3barSeparatorWidth
+		if block.SeparatorBlockWidth != nil {
+			gap = *block.SeparatorBlockWidth
+		}
+		if gap > 0 {
+			pieces = append(pieces, &TextPiece{Gap: gap, Align: RIGHT})
+		}
+	}
+	for i, j := 0, len(pieces)-1; i < j; i, j = i+1, j-1 {
+		pieces[i], pieces[j] = pieces[j], pieces[i]
+	}
+	ip.last = pieces
+	return pieces
+}
+
+// i3barPieces returns pieces block is drawn as, left to right.
+func i3barPieces(block i3barBlock) []*TextPiece {
+	base := TextPiece{Text: block.FullText, Align: RIGHT, Urgent: block.Urgent, Name: block.Name}
+	if block.Instance != "" {
+		base.Name += "/" + block.Instance
+	}
+	if block.Color != "" {
+		if color, err := parsePangoColor(block.Color); err == nil {
+			base.Foreground = NewBGRA(color)
+		} else {
+			stats.parseErrors.Add(1)
+			logEvery(hotLogInterval, WARN, "Problem parsing i3bar color `%s`: %s", block.Color, err)
+		}
+	}
+	if block.Background != "" {
+		if color, err := parsePangoColor(block.Background); err == nil {
+			base.Background = NewBGRA(color)
+		} else {
+			stats.parseErrors.Add(1)
+			logEvery(hotLogInterval, WARN, "Problem parsing i3bar color `%s`: %s", block.Background, err)
+		}
+	}
+	// Minimum width given as text cannot be measured without a font.
+	if width, ok := block.MinWidth.(float64); ok && width > 0 {
+		base.MinWidth = int(width)
+	}
+
+	if block.Markup != "pango" {
+		return []*TextPiece{&base}
+	}
+	pieces := (&PangoParser{}).Scan(strings.NewReader(block.FullText))
+	for i, piece := range pieces {
+		styled := base
+		styled.Text = piece.Text
+		styled.Font, styled.FontName = piece.Font, piece.FontName
+		styled.Bold, styled.Italic, styled.Underline = piece.Bold, piece.Italic, piece.Underline
+		if piece.Foreground != nil {
+			styled.Foreground = piece.Foreground
+		}
+		if piece.Background != nil {
+			styled.Background = piece.Background
+		}
+		// Pieces are not padded together, so only the last one is.
+		if i != len(pieces)-1 {
+			styled.MinWidth = 0
+		}
+		pieces[i] = &styled
+	}
+	return pieces
+}
+
+// AutoParser reads every input line as i3bar protocol, if it looks
+// like one, or as gobar markup otherwise, e.g. for status generators
+// switching between the two when restarted.
+type AutoParser struct {
+	Text  Parser
+	I3bar *I3barParser
+}
+
+// Scan scans line with parser of its format.
+func (ap *AutoParser) Scan(r io.Reader) []*TextPiece {
+	parser, line := ap.parser(r)
+	return parser.Scan(strings.NewReader(line))
+}
+
+// ScanWithErrors works like Scan, but also returns problems
+// parser of the line format found in it, if it reports them.
+func (ap *AutoParser) ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError) {
+	parser, line := ap.parser(r)
+	return scanWithErrors(parser, strings.NewReader(line))
+}
+
+// parser reads line from r and returns parser of its format, with the line.
+func (ap *AutoParser) parser(r io.Reader) (Parser, string) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logEvery(hotLogInterval, WARN, "Problem reading input line: %s", err)
+	}
+	if isI3barLine(string(data)) {
+		return ap.I3bar, string(data)
+	}
+	return ap.Text, string(data)
+}
@@ -0,0 +1,114 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jezek/xgbutil/xgraphics"
+)
+
+func TestIsI3barLine(t *testing.T) {
+	tests := []struct {
+		input  string
+		output bool
+	}{
+		{`{"version":1}`, true},
+		{`{ "version": 1, "click_events": true }`, true},
+		{`{"full_text":"test"}`, false},
+		{"[", true},
+		{`[[{"full_text":"test"}],`, true},
+		{`[{"full_text":"test"}],`, true},
+		{`,[{"full_text":"test"}]`, true},
+		{"[vol 50%]", false},
+		{"{F1test}", false},
+		{"test", false},
+	}
+
+	for i, test := range tests {
+		actual := isI3barLine(test.input)
+		assertEqual(t, test.input, test.output, actual, "IsI3barLine", i)
+	}
+}
+
+func TestI3barParserScan(t *testing.T) {
+	red := &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+	gray := &xgraphics.BGRA{B: 0x33, G: 0x33, R: 0x33, A: 0xFF}
+
+	parser := &I3barParser{}
+	tests := []struct {
+		input  string
+		output []*TextPiece
+	}{
+		{`{"version":1}`, nil},
+		{"[", nil},
+		{`[[{"full_text":"test"}],`, []*TextPiece{{Text: "test", Align: RIGHT}}},
+		{`[{"full_text":"test1","color":"#ff0000"},{"full_text":"test2","urgent":true,"name":"bat","instance":"0"}]`, []*TextPiece{
+			{Text: "test2", Align: RIGHT, Urgent: true, Name: "bat/0"},
+			{Gap: 9, Align: RIGHT},
+			{Text: "test1", Align: RIGHT, Foreground: red},
+		}},
+		{`,[{"full_text":"test1","separator_block_width":0,"min_width":40},{"full_text":"test2","background":"#333333"}]`, []*TextPiece{
+			{Text: "test2", Align: RIGHT, Background: gray},
+			{Text: "test1", Align: RIGHT, MinWidth: 40},
+		}},
+		{`,[{"full_text":"<b>test1</b> test2","markup":"pango","color":"#ff0000"}]`, []*TextPiece{
+			{Text: " test2", Align: RIGHT, Foreground: red},
+			{Text: "test1", Align: RIGHT, Foreground: red, Bold: true},
+		}},
+		{`,[{"full_text":}]`, []*TextPiece{
+			{Text: " test2", Align: RIGHT, Foreground: red},
+			{Text: "test1", Align: RIGHT, Foreground: red, Bold: true},
+		}},
+	}
+
+	for i, test := range tests {
+		actual := parser.Scan(strings.NewReader(test.input))
+		assertEqual(t, test.input, test.output, actual, "I3barParserScan", i)
+	}
+}
+
+func TestAutoParserScan(t *testing.T) {
+	textParser := NewTextParser()
+	parser := &AutoParser{Text: textParser, I3bar: &I3barParser{}}
+
+	tests := []struct {
+		input  string
+		output []*TextPiece
+	}{
+		{"test1", []*TextPiece{{Text: "test1"}}},
+		{`{"version":1}`, nil},
+		{"[", nil},
+		{`[{"full_text":"test2"}],`, []*TextPiece{{Text: "test2", Align: RIGHT}}},
+		{"{F1test3}", []*TextPiece{{Text: "test3", Font: 1}}},
+	}
+
+	for i, test := range tests {
+		actual := parser.Scan(strings.NewReader(test.input))
+		for _, t := range actual {
+			t.Origin = nil
+		}
+		assertEqual(t, test.input, test.output, actual, "AutoParserScan", i)
+	}
+}
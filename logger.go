@@ -0,0 +1,143 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogLevel defines how important a logged message is.
+type LogLevel uint8
+
+const (
+	DEBUG LogLevel = iota
+	INFO
+	WARN
+	ERROR
+	// QUIET is above every message level, so nothing is logged.
+	QUIET
+)
+
+var logLevelNames = map[LogLevel]string{
+	DEBUG: "debug",
+	INFO:  "info",
+	WARN:  "warn",
+	ERROR: "error",
+}
+
+func (l *LogLevel) String() string {
+	return logLevelNames[*l]
+}
+
+func (l *LogLevel) Set(value string) error {
+	for level, name := range logLevelNames {
+		if name == value {
+			*l = level
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid log level `%s`", value)
+}
+
+// quietFlag is a boolean flag setting logLevel to QUIET.
+type quietFlag struct{}
+
+func (quietFlag) String() string { return "false" }
+
+func (quietFlag) IsBoolFlag() bool { return true }
+
+func (quietFlag) Set(value string) error {
+	quiet, err := strconv.ParseBool(value)
+	if quiet {
+		logLevel = QUIET
+	}
+	return err
+}
+
+// logLevel is the least important level of messages that get logged.
+var logLevel = INFO
+
+// logf logs a message, unless its level is less important than logLevel.
+// Fatal errors are not affected, see fatal.
+func logf(level LogLevel, format string, v ...interface{}) {
+	if level < logLevel {
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// hotLogInterval is the time within which identical warnings
+// coming from the input and drawing paths are logged only once.
+const hotLogInterval = 10 * time.Second
+
+// logLimiter remembers when messages were last logged,
+// so that identical messages can be collapsed.
+type logLimiter struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// maxLimitedMessages is the number of remembered messages
+// above which the expired ones get forgotten.
+const maxLimitedMessages = 1024
+
+// allow checks whether msg can be logged at now, given that identical
+// messages should be logged at most once every interval.
+func (l *logLimiter) allow(msg string, now time.Time, interval time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.last[msg]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if l.last == nil {
+		l.last = map[string]time.Time{}
+	}
+	if len(l.last) >= maxLimitedMessages {
+		for m, last := range l.last {
+			if now.Sub(last) >= interval {
+				delete(l.last, m)
+			}
+		}
+	}
+	l.last[msg] = now
+	return true
+}
+
+var limiter logLimiter
+
+// logEvery logs a message like logf, but only if identical message
+// was not logged within the last interval.
+func logEvery(interval time.Duration, level LogLevel, format string, v ...interface{}) {
+	if level < logLevel {
+		return
+	}
+	msg := fmt.Sprintf(format, v...)
+	if limiter.allow(msg, time.Now(), interval) {
+		log.Print(msg)
+	}
+}
@@ -0,0 +1,128 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLogLevelSet(t *testing.T) {
+	tests := []struct {
+		input  string
+		output LogLevel
+		err    error
+	}{
+		{"debug", DEBUG, nil},
+		{"info", INFO, nil},
+		{"warn", WARN, nil},
+		{"error", ERROR, nil},
+		{"wrongo", INFO, fmt.Errorf("invalid log level `wrongo`")},
+	}
+
+	for i, test := range tests {
+		level := INFO
+
+		err := level.Set(test.input)
+
+		assertEqual(t, test.input, test.output, level, "LogLevelSet", i)
+		assertEqualError(t, test.err, err, "LogLevelSet", i)
+	}
+}
+
+func TestLogf(t *testing.T) {
+	tests := []struct {
+		level  LogLevel
+		output string
+	}{
+		{DEBUG, "debug\ninfo\nwarn\nerror\n"},
+		{INFO, "info\nwarn\nerror\n"},
+		{WARN, "warn\nerror\n"},
+		{ERROR, "error\n"},
+		{QUIET, ""},
+	}
+
+	defer func(orig LogLevel) { logLevel = orig }(logLevel)
+	log.SetFlags(0)
+	defer log.SetFlags(log.LstdFlags)
+
+	for i, test := range tests {
+		var stderr bytes.Buffer
+		log.SetOutput(&stderr)
+		logLevel = test.level
+
+		logf(DEBUG, "debug")
+		logf(INFO, "info")
+		logf(WARN, "warn")
+		logf(ERROR, "error")
+
+		assertEqual(t, test.level, test.output, stderr.String(), "Logf", i)
+	}
+
+	log.SetOutput(os.Stderr)
+}
+
+func TestLogLimiterAllow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		msg    string
+		after  time.Duration
+		output bool
+	}{
+		{"test1", 0, true},
+		{"test1", 0, false},
+		{"test2", 0, true},
+		{"test1", 5 * time.Second, false},
+		{"test1", 10 * time.Second, true},
+		{"test1", 15 * time.Second, false},
+		{"test2", 15 * time.Second, true},
+	}
+
+	var l logLimiter
+	for i, test := range tests {
+		actual := l.allow(test.msg, start.Add(test.after), 10*time.Second)
+
+		assertEqual(t, test.msg, test.output, actual, "LogLimiterAllow", i)
+	}
+}
+
+func TestLogEvery(t *testing.T) {
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	log.SetFlags(0)
+	limiter = logLimiter{}
+
+	for i := 0; i < 1000; i++ {
+		logEvery(time.Hour, WARN, "test %d", 1)
+		logEvery(time.Hour, WARN, "test %d", 2)
+	}
+
+	assertEqual(t, nil, "test 1\ntest 2\n", stderr.String(), "LogEvery", 0)
+
+	log.SetFlags(log.LstdFlags)
+	log.SetOutput(os.Stderr)
+}
@@ -0,0 +1,98 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// metrics counts what gobar does, so that its performance can be observed.
+type metrics struct {
+	framesDrawn   atomic.Int64
+	drawTime      atomic.Int64
+	linesParsed   atomic.Int64
+	parseErrors   atomic.Int64
+	framesDropped atomic.Int64
+}
+
+// stats are metrics of the running gobar.
+var stats metrics
+
+// drawn records a frame that took d to draw.
+func (m *metrics) drawn(d time.Duration) {
+	m.framesDrawn.Add(1)
+	m.drawTime.Add(int64(d))
+}
+
+// write writes metrics to w in Prometheus text format.
+func (m *metrics) write(w io.Writer) error {
+	frames := m.framesDrawn.Load()
+	drawTime := time.Duration(m.drawTime.Load())
+	var average time.Duration
+	if frames > 0 {
+		average = drawTime / time.Duration(frames)
+	}
+	_, err := fmt.Fprintf(w, `# HELP gobar_frames_drawn_total Frames drawn.
+# TYPE gobar_frames_drawn_total counter
+gobar_frames_drawn_total %d
+# HELP gobar_draw_duration_seconds Time spent drawing frames.
+# TYPE gobar_draw_duration_seconds summary
+gobar_draw_duration_seconds_sum %g
+gobar_draw_duration_seconds_count %d
+# HELP gobar_draw_duration_average_seconds Average time spent drawing a frame.
+# TYPE gobar_draw_duration_average_seconds gauge
+gobar_draw_duration_average_seconds %g
+# HELP gobar_lines_parsed_total Input lines parsed.
+# TYPE gobar_lines_parsed_total counter
+gobar_lines_parsed_total %d
+# HELP gobar_parse_errors_total Problems found while parsing input lines.
+# TYPE gobar_parse_errors_total counter
+gobar_parse_errors_total %d
+# HELP gobar_frames_dropped_total Input lines skipped, because newer ones came before they got drawn.
+# TYPE gobar_frames_dropped_total counter
+gobar_frames_dropped_total %d
+`,
+		frames, drawTime.Seconds(), frames, average.Seconds(),
+		m.linesParsed.Load(), m.parseErrors.Load(), m.framesDropped.Load(),
+	)
+	return err
+}
+
+// serveMetrics serves stats over HTTP at addr, under /metrics.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := stats.write(w); err != nil {
+			logEvery(hotLogInterval, ERROR, "Could not write metrics: %s", err)
+		}
+	})
+	logf(INFO, "Serving metrics at `%s`", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logf(ERROR, "Could not serve metrics: %s", err)
+	}
+}
@@ -0,0 +1,58 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	var m metrics
+	m.drawn(time.Second)
+	m.drawn(2 * time.Second)
+	m.linesParsed.Add(5)
+	m.parseErrors.Add(1)
+	m.framesDropped.Add(2)
+
+	var out strings.Builder
+	err := m.write(&out)
+	assertEqualError(t, nil, err, "MetricsWrite", 0)
+
+	var values []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			values = append(values, line)
+		}
+	}
+	assertEqual(t, "metrics", []string{
+		"gobar_frames_drawn_total 2",
+		"gobar_draw_duration_seconds_sum 3",
+		"gobar_draw_duration_seconds_count 2",
+		"gobar_draw_duration_average_seconds 1.5",
+		"gobar_lines_parsed_total 5",
+		"gobar_parse_errors_total 1",
+		"gobar_frames_dropped_total 2",
+	}, values, "MetricsWrite", 0)
+}
@@ -0,0 +1,61 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSdNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = sdNotify(socket, "READY=1")
+	assertEqualError(t, nil, err, "SdNotify", 0)
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	assertEqualError(t, nil, err, "SdNotify", 0)
+	assertEqual(t, socket, "READY=1", string(buf[:n]), "SdNotify", 0)
+
+	err = sdNotify("", "READY=1")
+	assertEqualError(t, errors.New("NOTIFY_SOCKET is not set"), err, "SdNotify", 1)
+}
+
+func TestNotifyReady_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ready")
+
+	err := notifyReady(path)
+	assertEqualError(t, nil, err, "NotifyReady_file", 0)
+
+	_, err = os.Stat(path)
+	assertEqualError(t, nil, err, "NotifyReady_file", 0)
+}
@@ -0,0 +1,192 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PangoParser is used to create a set of TextPieces from a subset
+// of Pango markup, i.e. <span> with color, background, font and style
+// attributes and <b>, <i>, <u> convenience tags. Other tags are accepted,
+// but do not change the formatting.
+type PangoParser struct {
+	// MultiLine makes newlines start new text rows,
+	// instead of ending the scan.
+	MultiLine bool
+}
+
+// Scan scans Pango markup and returns array of TextPieces.
+// Possible empty pieces are omitted in the returned array.
+func (pp *PangoParser) Scan(r io.Reader) []*TextPiece {
+	var text []*TextPiece
+
+	decoder := xml.NewDecoder(io.MultiReader(
+		strings.NewReader("<markup>"), r, strings.NewReader("</markup>"),
+	))
+	decoder.Strict = false
+
+	stack := []*TextPiece{{}}
+	var row uint
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stats.parseErrors.Add(1)
+			logEvery(hotLogInterval, WARN, "Problem parsing pango markup: %s", err)
+			break
+		}
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			piece := *current
+			applyPangoTag(&piece, t)
+			stack = append(stack, &piece)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			for i, line := range strings.Split(string(t), "\n") {
+				if i > 0 {
+					if !pp.MultiLine {
+						return text
+					}
+					row++
+				}
+				if line == "" {
+					continue
+				}
+				piece := *current
+				piece.Text = line
+				piece.Row = row
+				text = append(text, &piece)
+			}
+		}
+	}
+	return text
+}
+
+// applyPangoTag sets piece formatting according to the tag and its attributes.
+func applyPangoTag(piece *TextPiece, tag xml.StartElement) {
+	switch tag.Name.Local {
+	case "b":
+		piece.Bold = true
+	case "i":
+		piece.Italic = true
+	case "u":
+		piece.Underline = true
+	case "span":
+		for _, attr := range tag.Attr {
+			applyPangoAttr(piece, attr.Name.Local, attr.Value)
+		}
+	}
+}
+
+// applyPangoAttr sets piece formatting according to a single <span> attribute.
+func applyPangoAttr(piece *TextPiece, name, value string) {
+	switch name {
+	case "color", "foreground", "fgcolor":
+		color, err := parsePangoColor(value)
+		if err != nil {
+			stats.parseErrors.Add(1)
+			logEvery(hotLogInterval, WARN, "Problem parsing `%s=%q`: %s", name, value, err)
+			return
+		}
+		piece.Foreground = NewBGRA(color)
+	case "background", "bgcolor":
+		color, err := parsePangoColor(value)
+		if err != nil {
+			stats.parseErrors.Add(1)
+			logEvery(hotLogInterval, WARN, "Problem parsing `%s=%q`: %s", name, value, err)
+			return
+		}
+		piece.Background = NewBGRA(color)
+	case "font", "font_desc":
+		piece.FontName = parsePangoFont(value)
+	case "face", "font_family":
+		piece.FontName = value
+	case "weight":
+		switch value {
+		case "normal", "light", "ultralight", "book":
+			piece.Bold = false
+		case "bold", "ultrabold", "heavy", "semibold", "ultraheavy":
+			piece.Bold = true
+		default:
+			weight, err := strconv.Atoi(value)
+			if err != nil {
+				stats.parseErrors.Add(1)
+				logEvery(hotLogInterval, WARN, "Problem parsing `%s=%q`: %s", name, value, err)
+				return
+			}
+			piece.Bold = weight >= 600
+		}
+	case "style", "font_style":
+		piece.Italic = value == "italic" || value == "oblique"
+	case "underline":
+		piece.Underline = value != "none"
+	}
+}
+
+// parsePangoColor turns #rgb, #rrggbb or #rrggbbaa color definition
+// into a hexagonal representation with alpha, i.e. 0xAARRGGBB.
+func parsePangoColor(value string) (uint64, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == len(value) {
+		return 0, fmt.Errorf("only hexadecimal colors are supported")
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return 0, fmt.Errorf("invalid color length")
+	}
+	color, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	return color>>8 | (color&0xff)<<24, nil
+}
+
+// parsePangoFont turns Pango font description, i.e. "Family Style Size",
+// into gobar font definition, i.e. "Family Style:Size".
+func parsePangoFont(desc string) string {
+	i := strings.LastIndexByte(desc, ' ')
+	if i == -1 {
+		return desc
+	}
+	size := strings.TrimSuffix(desc[i+1:], "px")
+	if _, err := strconv.ParseFloat(size, 64); err != nil {
+		return desc
+	}
+	return desc[:i] + ":" + size
+}
@@ -0,0 +1,133 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jezek/xgbutil/xgraphics"
+)
+
+var PangoScanTests = []struct {
+	input    string
+	expected []*TextPiece
+}{
+	{"test", []*TextPiece{
+		{Text: "test"},
+	}},
+	{"test\n", []*TextPiece{
+		{Text: "test"},
+	}},
+	{"test1\ntest2", []*TextPiece{
+		{Text: "test1"},
+	}},
+	{"<b>test</b>", []*TextPiece{
+		{Text: "test", Bold: true},
+	}},
+	{"<i>test</i>", []*TextPiece{
+		{Text: "test", Italic: true},
+	}},
+	{"<u>test</u>", []*TextPiece{
+		{Text: "test", Underline: true},
+	}},
+	{"test1<b>test2</b>test3", []*TextPiece{
+		{Text: "test1"}, {Text: "test2", Bold: true}, {Text: "test3"},
+	}},
+	{"<b>test1<i>test2</i></b>", []*TextPiece{
+		{Text: "test1", Bold: true}, {Text: "test2", Bold: true, Italic: true},
+	}},
+	{`<span color="#00AA33">test</span>`, []*TextPiece{
+		{Text: "test", Foreground: &xgraphics.BGRA{B: 0x33, G: 0xAA, R: 0x00, A: 0xFF}},
+	}},
+	{`<span foreground="#0a3">test</span>`, []*TextPiece{
+		{Text: "test", Foreground: &xgraphics.BGRA{B: 0x33, G: 0xAA, R: 0x00, A: 0xFF}},
+	}},
+	{`<span background="#AA00FF33">test</span>`, []*TextPiece{
+		{Text: "test", Background: &xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0xAA, A: 0x33}},
+	}},
+	{`<span font_desc="DejaVu Sans Bold 10">test</span>`, []*TextPiece{
+		{Text: "test", FontName: "DejaVu Sans Bold:10"},
+	}},
+	{`<span font="Monospace">test</span>`, []*TextPiece{
+		{Text: "test", FontName: "Monospace"},
+	}},
+	{`<span face="DejaVu Sans">test</span>`, []*TextPiece{
+		{Text: "test", FontName: "DejaVu Sans"},
+	}},
+	{`<span weight="bold">test1</span><span weight="700">test2</span><span weight="400">test3</span>`, []*TextPiece{
+		{Text: "test1", Bold: true}, {Text: "test2", Bold: true}, {Text: "test3"},
+	}},
+	{`<b><span weight="normal">test</span></b>`, []*TextPiece{
+		{Text: "test"},
+	}},
+	{`<span style="italic" underline="single">test</span>`, []*TextPiece{
+		{Text: "test", Italic: true, Underline: true},
+	}},
+	{"<tt>test1</tt> &amp; test2", []*TextPiece{
+		{Text: "test1"}, {Text: " & test2"},
+	}},
+}
+
+func TestPangoScan(t *testing.T) {
+	parser := &PangoParser{}
+
+	for i, tt := range PangoScanTests {
+		actual := parser.Scan(strings.NewReader(tt.input))
+
+		assertEqual(t, tt.input, tt.expected, actual, "PangoScan", i)
+	}
+}
+
+func TestPangoScan_multiLine(t *testing.T) {
+	parser := &PangoParser{MultiLine: true}
+
+	input := "<b>test1\ntest2</b>\ntest3"
+	expected := []*TextPiece{
+		{Text: "test1", Bold: true}, {Text: "test2", Bold: true, Row: 1}, {Text: "test3", Row: 2},
+	}
+
+	actual := parser.Scan(strings.NewReader(input))
+
+	assertEqual(t, input, expected, actual, "PangoScan_multiLine", 0)
+}
+
+func TestPangoScan_badColor(t *testing.T) {
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	limiter = logLimiter{}
+
+	parser := &PangoParser{}
+	input := `<span color="red">test</span>`
+
+	actual := parser.Scan(strings.NewReader(input))
+	logs, _ := stderr.ReadString('\n')
+
+	assertEqual(t, input, []*TextPiece{{Text: "test"}}, actual, "PangoScan_badColor", 0)
+	assertEqual(t, input, "Problem parsing `color=\"red\"`: only hexadecimal colors are supported\n", logs[20:], "PangoScan_badColor", 0)
+
+	log.SetOutput(os.Stderr)
+}
@@ -24,10 +24,14 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"math"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/jezek/xgbutil/xgraphics"
 )
@@ -38,6 +42,7 @@ type Align uint8
 const (
 	LEFT Align = iota
 	RIGHT
+	CENTER
 )
 
 // EndScan is an artifical Error.
@@ -66,19 +71,186 @@ type TextPiece struct {
 	Background *xgraphics.BGRA
 	Screens    []uint
 	NotScreens []uint
+	Row        uint
+	MinWidth   int
+	FontName   string
+	Bold       bool
+	Italic     bool
+	Underline  bool
+	Outline    *xgraphics.BGRA
+	// AbsX, if set, is x the piece is drawn at, outside of the flow
+	// of other pieces. Negative values count from the right edge.
+	// Center aligned pieces are centered around it.
+	AbsX *int
+	// AbsXPercent makes AbsX a percentage of the bar width.
+	AbsXPercent bool
+	// ZIndex orders drawing of pieces with AbsX, which are drawn
+	// after all the others, from the lowest ZIndex to the highest.
+	ZIndex int
+	// Gap is empty space put at the cursor before the piece,
+	// usually without any text of its own.
+	Gap int
+	// LeadPad moves the piece text right, within the piece.
+	LeadPad int
+	// BlockHeight, if set, is height of the background block drawn
+	// centered behind the piece, instead of over the whole bar height.
+	BlockHeight int
+	// BoxBorder, if set, makes background of the piece only a box
+	// of that border width around it, leaving the inside as it is.
+	BoxBorder int
+	// Column, if set, is a column the piece is in. Pieces in the same
+	// column are as wide as the widest of them.
+	Column *int
+	// Spin makes the piece a spinner, drawing one of its text characters
+	// at a time, or of the default spinner frames, if it has no text.
+	Spin bool
+	// Graph, if set, are values plotted as a sparkline before the piece
+	// text, GraphWidth pixels wide and GraphHeight pixels high,
+	// or as high as the row, if GraphHeight is 0.
+	Graph       []float64
+	GraphWidth  int
+	GraphHeight int
+	// Rotate is a clockwise rotation of the piece text in degrees,
+	// one of 0, 90, 180 or 270. Text rotated sideways is as wide
+	// as the font is high.
+	Rotate int
+	// Urgent makes the piece background pulse towards the urgent color
+	// to draw attention to it.
+	Urgent bool
+	// Name identifies the piece, and pieces nested within it,
+	// to external scripts.
+	Name string
+	// Copy, if set, is copied to the clipboard on clicking the piece,
+	// instead of its text.
+	Copy string
 
 	Origin *TextPiece
 }
 
+// String returns human readable description of the piece,
+// i.e. its text followed by all the formatting that is not default.
+func (tp *TextPiece) String() string {
+	parts := []string{strconv.Quote(tp.Text)}
+	if tp.Name != "" {
+		parts = append(parts, "name="+strconv.Quote(tp.Name))
+	}
+	if tp.Copy != "" {
+		parts = append(parts, "copy="+strconv.Quote(tp.Copy))
+	}
+	if tp.FontName != "" {
+		parts = append(parts, "font="+strconv.Quote(tp.FontName))
+	} else if tp.Font != 0 {
+		parts = append(parts, fmt.Sprintf("font=%d", tp.Font))
+	}
+	if tp.Align == RIGHT {
+		parts = append(parts, "align=right")
+	} else if tp.Align == CENTER {
+		parts = append(parts, "align=center")
+	}
+	if tp.AbsX != nil && tp.AbsXPercent {
+		parts = append(parts, fmt.Sprintf("x=%d%%", *tp.AbsX))
+	} else if tp.AbsX != nil {
+		parts = append(parts, fmt.Sprintf("x=%d", *tp.AbsX))
+	}
+	if tp.ZIndex != 0 {
+		parts = append(parts, fmt.Sprintf("z=%d", tp.ZIndex))
+	}
+	if tp.Rotate != 0 {
+		parts = append(parts, fmt.Sprintf("rotate=%d", tp.Rotate))
+	}
+	if tp.Foreground != nil {
+		parts = append(parts, "fg="+formatBGRA(tp.Foreground))
+	}
+	if tp.Background != nil {
+		parts = append(parts, "bg="+formatBGRA(tp.Background))
+	}
+	if tp.Outline != nil {
+		parts = append(parts, "outline="+formatBGRA(tp.Outline))
+	}
+	if tp.BlockHeight != 0 {
+		parts = append(parts, fmt.Sprintf("blockheight=%d", tp.BlockHeight))
+	}
+	if tp.BoxBorder != 0 {
+		parts = append(parts, fmt.Sprintf("box=%d", tp.BoxBorder))
+	}
+	if len(tp.Screens) > 0 {
+		parts = append(parts, "screens="+formatScreens(tp.Screens))
+	}
+	if len(tp.NotScreens) > 0 {
+		parts = append(parts, "notscreens="+formatScreens(tp.NotScreens))
+	}
+	if tp.Row != 0 {
+		parts = append(parts, fmt.Sprintf("row=%d", tp.Row))
+	}
+	if tp.Gap != 0 {
+		parts = append(parts, fmt.Sprintf("gap=%d", tp.Gap))
+	}
+	if tp.LeadPad != 0 {
+		parts = append(parts, fmt.Sprintf("leadpad=%d", tp.LeadPad))
+	}
+	if tp.MinWidth != 0 {
+		parts = append(parts, fmt.Sprintf("minwidth=%d", tp.MinWidth))
+	}
+	if tp.Column != nil {
+		parts = append(parts, fmt.Sprintf("column=%d", *tp.Column))
+	}
+	if tp.Spin {
+		parts = append(parts, "spin")
+	}
+	if tp.Graph != nil {
+		values := make([]string, len(tp.Graph))
+		for i, value := range tp.Graph {
+			values[i] = strconv.FormatFloat(value, 'g', -1, 64)
+		}
+		parts = append(parts, fmt.Sprintf("graph=%s:%d:%d", strings.Join(values, ","), tp.GraphWidth, tp.GraphHeight))
+	}
+	if tp.Urgent {
+		parts = append(parts, "urgent")
+	}
+	if tp.Bold {
+		parts = append(parts, "bold")
+	}
+	if tp.Italic {
+		parts = append(parts, "italic")
+	}
+	if tp.Underline {
+		parts = append(parts, "underline")
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatBGRA returns color in 0xAARRGGBB form, the one NewBGRA takes.
+func formatBGRA(color *xgraphics.BGRA) string {
+	return fmt.Sprintf("0x%02X%02X%02X%02X", color.A, color.R, color.G, color.B)
+}
+
+// formatScreens returns comma separated list of screens.
+func formatScreens(screens []uint) string {
+	str := make([]string, len(screens))
+	for i, screen := range screens {
+		str[i] = strconv.FormatUint(uint64(screen), 10)
+	}
+	return strings.Join(str, ",")
+}
+
+// Parser creates a set of TextPieces from a textual definition.
+type Parser interface {
+	Scan(r io.Reader) []*TextPiece
+}
+
 // TextParser is used to create a set of TextPieces from a textual definition.
 type TextParser struct {
+	// MultiLine makes newlines start new text rows,
+	// instead of ending the scan.
+	MultiLine bool
+
 	rgbPattern *regexp.Regexp
 }
 
 // NewTextParser creates TextParser instance with
 // correct necessary regexp definitions.
 func NewTextParser() *TextParser {
-	return &TextParser{regexp.MustCompile(`^0[xX][0-9a-fA-F]{8}$`)}
+	return &TextParser{rgbPattern: regexp.MustCompile(`^0[xX][0-9a-fA-F]{8}$`)}
 }
 
 // Tokenize turns textual definition into a series of valid tokens.
@@ -90,23 +262,60 @@ func (tp *TextParser) Tokenize(
 		return
 	}
 	switch {
+	case data[0] == '\n' && tp.MultiLine:
+		advance, token, err = 1, data[:1], nil
 	case data[0] == '\n':
 		err = EndScan{}
 	case len(data) < 2:
 		advance, token, err = 1, data[:1], nil
 	case string(data[:2]) == "{F":
 		advance, token, err = 2, data[:2], nil
+	case len(data) >= 5 && string(data[:5]) == "{SPIN":
+		advance, token, err = 5, data[:5], nil
 	case string(data[:2]) == "{S":
 		advance, token, err = 2, data[:2], nil
+	case len(data) >= 7 && string(data[:7]) == "{URGENT":
+		advance, token, err = 7, data[:7], nil
+	case string(data[:2]) == "{X":
+		advance, token, err = 2, data[:2], nil
+	case string(data[:2]) == "{Z":
+		advance, token, err = 2, data[:2], nil
+	case string(data[:2]) == "{N":
+		advance, token, err = 2, data[:2], nil
 	case len(data) < 3:
-		advance, token, err = 1, data[:1], nil
+		i := textRun(data)
+		advance, token, err = i, data[:i], nil
 	case string(data[:3]) == "{CF":
 		advance, token, err = 3, data[:3], nil
 	case string(data[:3]) == "{CB":
 		advance, token, err = 3, data[:3], nil
+	case len(data) >= 5 && string(data[:5]) == "{COPY":
+		advance, token, err = 5, data[:5], nil
+	case len(data) >= 4 && string(data[:4]) == "{COL":
+		advance, token, err = 4, data[:4], nil
+	case string(data[:3]) == "{CO":
+		advance, token, err = 3, data[:3], nil
+	case len(data) >= 4 && string(data[:4]) == "{ALL":
+		advance, token, err = 4, data[:4], nil
+	case len(data) >= 4 && string(data[:4]) == "{BOX":
+		advance, token, err = 4, data[:4], nil
+	case len(data) >= 4 && string(data[:4]) == "{ROT":
+		advance, token, err = 4, data[:4], nil
+	case len(data) >= 6 && string(data[:6]) == "{GRAPH":
+		advance, token, err = 6, data[:6], nil
+	case len(data) >= 4 && string(data[:4]) == "{GAP":
+		advance, token, err = 4, data[:4], nil
+	case len(data) >= 5 && string(data[:5]) == "{PILL":
+		advance, token, err = 5, data[:5], nil
 	case string(data[:3]) == "{AR":
 		advance, token, err = 3, data[:3], nil
-	case len(data) >= 10 && tp.rgbPattern.Match(data[:10]):
+	case string(data[:3]) == "{AC":
+		advance, token, err = 3, data[:3], nil
+	case string(data[:3]) == "{MW":
+		advance, token, err = 3, data[:3], nil
+	case string(data[:3]) == "{PX":
+		advance, token, err = 3, data[:3], nil
+	case len(data) >= 10 && data[0] == '0' && tp.rgbPattern.Match(data[:10]):
 		advance, token, err = 10, data[:10], nil
 	case ('0' <= data[0] && data[0] <= '9') || data[0] == '-':
 		i := 0
@@ -120,18 +329,75 @@ func (tp *TextParser) Tokenize(
 			i++
 		}
 		advance, token, err = i, data[:i], nil
-	default: // Also contains '}' and ','
-		// TODO: Parsing whole text piece here, instead of returning
-		// char-by-char, should perform better
-		advance, token, err = 1, data[:1], nil
+	default:
+		i := textRun(data)
+		advance, token, err = i, data[:i], nil
 	}
 	return
 }
 
-// Scan scans textual definition and returns array of TextPieces.
-// Possible empty pieces are omitted in the returned array.
+// textRun returns length of plain text at the beginning of data,
+// up to the next char that could mean something to Scan.
+// Such chars make a run of their own.
+func textRun(data []byte) int {
+	i := bytes.IndexAny(data, "{}\\,:\n ")
+	if i == -1 {
+		return len(data)
+	}
+	if i == 0 {
+		return 1
+	}
+	return i
+}
+
+// maxScreenRange is the maximum number of screens in {S range.
+const maxScreenRange = 64
+
+// ParseError is a problem found in the input while scanning it.
+type ParseError struct {
+	// Markup is the offending part of the input.
+	Markup string
+	// Err is the reason it could not be parsed.
+	Err error
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("invalid `%s`: %s", e.Markup, e.Err)
+}
+
+// ErrorScanner is a Parser that can also return problems found
+// in the input, instead of only logging them.
+type ErrorScanner interface {
+	Parser
+	ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError)
+}
+
+// scanWithErrors scans r with parser, returning problems found
+// in it as well, if parser reports them.
+func scanWithErrors(parser Parser, r io.Reader) ([]*TextPiece, []ParseError) {
+	if scanner, ok := parser.(ErrorScanner); ok {
+		return scanner.ScanWithErrors(r)
+	}
+	return parser.Scan(r), nil
+}
+
+// Scan scans textual definition and returns array of TextPieces,
+// logging problems found in it.
 func (tp *TextParser) Scan(r io.Reader) []*TextPiece {
+	text, errs := tp.ScanWithErrors(r)
+	for _, err := range errs {
+		logEvery(hotLogInterval, WARN, "Problem parsing `%s`: %s", err.Markup, err.Err)
+	}
+	return text
+}
+
+// ScanWithErrors scans textual definition and returns array of TextPieces,
+// together with problems found in it. Offending parts of the definition
+// are kept as text.
+// Possible empty pieces are omitted in the returned array.
+func (tp *TextParser) ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError) {
 	var text []*TextPiece
+	var errs []ParseError
 
 	scanner := bufio.NewScanner(r)
 
@@ -156,8 +422,25 @@ func (tp *TextParser) Scan(r io.Reader) []*TextPiece {
 		} else {
 			*newCurrent = *currentText
 			newCurrent.Origin = currentText
+			// Minimum width concerns only the piece it was set on.
+			newCurrent.MinWidth = 0
 		}
 		newCurrent.Text = ""
+		// Position, gap and padding concern only the text
+		// directly following them.
+		newCurrent.AbsX = nil
+		newCurrent.AbsXPercent = false
+		newCurrent.Gap = 0
+		newCurrent.LeadPad = 0
+		newCurrent.Column = nil
+		newCurrent.Spin = false
+		newCurrent.Graph = nil
+		newCurrent.GraphWidth = 0
+		newCurrent.GraphHeight = 0
+		// Screens get appended to, so they must not be shared
+		// with the pieces they were copied from.
+		newCurrent.Screens = append([]uint(nil), newCurrent.Screens...)
+		newCurrent.NotScreens = append([]uint(nil), newCurrent.NotScreens...)
 		if currentText.Align == RIGHT {
 			i := currentIndex()
 			text = append(text, &TextPiece{})
@@ -171,47 +454,175 @@ func (tp *TextParser) Scan(r io.Reader) []*TextPiece {
 	}
 
 	logPieceError := func(err error, pieces ...string) {
-		log.Printf("Problem parsing `%q`: %s", pieces, err)
-		for _, piece := range pieces {
-			currentText.Text += piece
-		}
+		stats.parseErrors.Add(1)
+		markup := strings.Join(pieces, "")
+		errs = append(errs, ParseError{Markup: markup, Err: err})
+		currentText.Text += markup
 	}
 
 	screening := false
 	escaping := false
 	bracketing := 0
-	for scanner.Scan() {
-		stext := scanner.Text()
+	// closedByRow counts pieces and brackets that rows ended,
+	// which closing brackets in the next rows are dropped for.
+	closedByRow := 0
+	var row uint
+	// pending holds a token that was read ahead, but not yet processed.
+	var pending string
+
+	// scanScreens reads screen, or range of screens in form of
+	// <first>-<last>, starting with text. Negative first screen means
+	// that screens are excluded. Token following a single screen is
+	// read ahead, to check for a range, and left pending.
+	scanScreens := func(stext, text string) (screens []uint, exclude bool) {
+		first, err := strconv.Atoi(text)
+		if err != nil {
+			logPieceError(err, stext, text)
+		}
+		exclude = text != "" && text[0] == '-'
+		if exclude {
+			first = -first
+		}
+		last := first
+		if err == nil && scanner.Scan() {
+			next := scanner.Text()
+			if end, err := strconv.Atoi(next); err == nil && next[0] == '-' {
+				last = -end
+			} else {
+				pending = next
+			}
+		}
+		return screenRange(first, last), exclude
+	}
+	// scanValue reads value following token stext. Value is either
+	// the very next token, or everything up to the closing colon,
+	// if it starts with one, e.g. {F:1:text}. Colons within such value
+	// can be escaped. It cannot span brackets or rows, though.
+	scanValue := func(stext string) (value string, delimited bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		if scanner.Text() != ":" {
+			return scanner.Text(), false
+		}
+		escaped := false
+		for scanner.Scan() {
+			next := scanner.Text()
+			switch {
+			case escaped:
+				value += next
+				escaped = false
+			case next == "\\":
+				escaped = true
+			case next == ":":
+				return value, true
+			case next == "\n" || next == "}" || next[0] == '{':
+				pending = next
+				logPieceError(errors.New("unclosed value"), stext, ":"+value)
+				return value, true
+			default:
+				value += next
+			}
+		}
+		logPieceError(errors.New("unclosed value"), stext, ":"+value)
+		return value, true
+	}
+	for pending != "" || scanner.Scan() {
+		stext := pending
+		if stext == "" {
+			stext = scanner.Text()
+		}
+		pending = ""
 		switch {
+		case stext == "\n":
+			// Every row starts anew, any unclosed pieces end here.
+			open := bracketing
+			for piece := currentText; piece.Origin != nil; piece = piece.Origin {
+				open++
+			}
+			if open > 0 {
+				stats.parseErrors.Add(1)
+				errs = append(errs, ParseError{
+					Markup: strings.Repeat("{", open),
+					Err:    fmt.Errorf("unclosed at the end of row %d", row),
+				})
+				closedByRow += open
+			}
+			row++
+			currentText = &TextPiece{Row: row}
+			text = append(text, currentText)
+			screening = false
+			escaping = false
+			bracketing = 0
 		case stext == "\\":
 			escaping = true
 			continue
 		case !escaping && stext == "{F":
-			scanner.Scan()
-			text := scanner.Text()
+			text, delimited := scanValue(stext)
 			font, err := strconv.Atoi(text)
-			if err != nil {
-				logPieceError(err, stext, text)
+			if err == nil || text == "" {
+				if err != nil {
+					logPieceError(err, stext, text)
+				}
+				newCurrent := moveCurrent(false)
+				newCurrent.Font = uint(font)
+				newCurrent.FontName = ""
+				break
+			}
+			name := text
+			if !delimited {
+				name, pending = scanInlineValue(scanner, text)
+			}
+			if name == "" {
+				logPieceError(errors.New("empty font name"), stext)
 			}
 			newCurrent := moveCurrent(false)
-			newCurrent.Font = uint(font)
+			newCurrent.FontName = name
+		case !escaping && stext == "{N":
+			name, delimited := scanValue(stext)
+			if !delimited {
+				name, pending = scanInlineValue(scanner, name)
+			}
+			if name == "" {
+				logPieceError(errors.New("empty piece name"), stext)
+			}
+			moveCurrent(false).Name = name
+		case !escaping && stext == "{COPY":
+			value, delimited := scanValue(stext)
+			if !delimited {
+				value, pending = scanInlineValue(scanner, value)
+			}
+			if value == "" {
+				logPieceError(errors.New("empty copy value"), stext)
+			}
+			moveCurrent(false).Copy = value
 		case !escaping && stext == "{S":
-			scanner.Scan()
-			text := scanner.Text()
-			screen, err := strconv.Atoi(text)
-			if err != nil {
-				logPieceError(err, stext, text)
+			text, delimited := scanValue(stext)
+			if delimited {
+				screens, notScreens, err := parseScreens(text)
+				if err != nil {
+					logPieceError(err, stext, ":"+text+":")
+				}
+				newCurrent := moveCurrent(false)
+				newCurrent.Screens = append(newCurrent.Screens, screens...)
+				newCurrent.NotScreens = append(newCurrent.NotScreens, notScreens...)
+				break
 			}
+			screens, exclude := scanScreens(stext, text)
 			newCurrent := moveCurrent(false)
-			if text[0] == '-' {
-				newCurrent.NotScreens = append(newCurrent.NotScreens, uint(-screen))
+			if exclude {
+				newCurrent.NotScreens = append(newCurrent.NotScreens, screens...)
 			} else {
-				newCurrent.Screens = append(newCurrent.Screens, uint(screen))
+				newCurrent.Screens = append(newCurrent.Screens, screens...)
 			}
 			screening = true
 		case !escaping && stext == "{CF":
-			scanner.Scan()
-			text := scanner.Text()
+			text, _ := scanValue(stext)
+			if text == "-" {
+				// Back to the bar default foreground.
+				moveCurrent(false).Foreground = nil
+				break
+			}
 			fg, err := strconv.ParseUint(text, 0, 32)
 			if err != nil {
 				logPieceError(err, stext, text)
@@ -219,17 +630,158 @@ func (tp *TextParser) Scan(r io.Reader) []*TextPiece {
 			newCurrent := moveCurrent(false)
 			newCurrent.Foreground = NewBGRA(fg)
 		case !escaping && stext == "{CB":
-			scanner.Scan()
-			text := scanner.Text()
+			text, _ := scanValue(stext)
+			if text == "-" {
+				// Back to the bar default background.
+				moveCurrent(false).Background = nil
+				break
+			}
 			bg, err := strconv.ParseUint(text, 0, 32)
 			if err != nil {
 				logPieceError(err, stext, text)
 			}
 			newCurrent := moveCurrent(false)
 			newCurrent.Background = NewBGRA(bg)
+		case !escaping && stext == "{CO":
+			text, _ := scanValue(stext)
+			if text == "-" {
+				// Back to the bar default outline.
+				moveCurrent(false).Outline = nil
+				break
+			}
+			outline, err := strconv.ParseUint(text, 0, 32)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.Outline = NewBGRA(outline)
+		case !escaping && stext == "{MW":
+			text, _ := scanValue(stext)
+			minWidth, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.MinWidth = minWidth
+		case !escaping && stext == "{COL":
+			text, _ := scanValue(stext)
+			column, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.Column = &column
+		case !escaping && stext == "{SPIN":
+			moveCurrent(false).Spin = true
+		case !escaping && stext == "{URGENT":
+			moveCurrent(false).Urgent = true
+		case !escaping && stext == "{PILL":
+			text, _ := scanValue(stext)
+			blockHeight, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.BlockHeight = blockHeight
+		case !escaping && stext == "{BOX":
+			text, _ := scanValue(stext)
+			border, err := strconv.Atoi(text)
+			if err == nil && border < 0 {
+				err = errors.New("box border cannot be negative")
+			}
+			if err != nil {
+				logPieceError(err, stext, text)
+				border = 0
+			}
+			moveCurrent(false).BoxBorder = border
+		case !escaping && stext == "{PX":
+			text, _ := scanValue(stext)
+			pad, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.LeadPad = pad
+		case !escaping && stext == "{X":
+			text, delimited := scanValue(stext)
+			percent := delimited && strings.HasSuffix(text, "%")
+			if percent {
+				text = strings.TrimSuffix(text, "%")
+			}
+			x, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			if err == nil && !delimited && scanner.Scan() {
+				next := scanner.Text()
+				percent = next[0] == '%'
+				if percent {
+					next = next[1:]
+				}
+				pending = next
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.AbsX = &x
+			newCurrent.AbsXPercent = percent
+		case !escaping && stext == "{ROT":
+			text, _ := scanValue(stext)
+			rotate, err := strconv.Atoi(text)
+			if err == nil && rotate%90 != 0 {
+				err = errors.New("rotation must be a multiple of 90 degrees")
+			}
+			if err != nil {
+				logPieceError(err, stext, text)
+				rotate = 0
+			}
+			moveCurrent(false).Rotate = (rotate%360 + 360) % 360
+		case !escaping && stext == "{Z":
+			text, _ := scanValue(stext)
+			z, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			moveCurrent(false).ZIndex = z
+		case !escaping && stext == "{GRAPH":
+			// Values hold colons of their own, so they are read whole,
+			// up to a space, optionally following the token too.
+			scanner.Scan()
+			first := scanner.Text()
+			if first == " " {
+				scanner.Scan()
+				first = scanner.Text()
+			}
+			var spec string
+			spec, pending = scanInlineValue(scanner, first)
+			values, width, height, err := parseGraph(spec)
+			if err != nil {
+				logPieceError(err, stext, spec)
+				moveCurrent(false)
+				break
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.Graph = values
+			newCurrent.GraphWidth = width
+			newCurrent.GraphHeight = height
+		case !escaping && stext == "{GAP":
+			text, _ := scanValue(stext)
+			gap, err := strconv.Atoi(text)
+			if err != nil {
+				logPieceError(err, stext, text)
+			}
+			newCurrent := moveCurrent(false)
+			newCurrent.Gap = gap
+		case !escaping && stext == "{ALL":
+			// Drawn everywhere, whatever screens it is nested within.
+			newCurrent := moveCurrent(false)
+			newCurrent.Screens = nil
+			newCurrent.NotScreens = nil
+			screening = false
 		case !escaping && stext == "{AR":
 			newCurrent := moveCurrent(false)
 			newCurrent.Align = RIGHT
+		case !escaping && stext == "{AC":
+			newCurrent := moveCurrent(false)
+			newCurrent.Align = CENTER
 		case !escaping && stext == "{":
 			bracketing++
 		case !escaping && stext == "}":
@@ -238,20 +790,24 @@ func (tp *TextParser) Scan(r io.Reader) []*TextPiece {
 				continue
 			}
 			screening = false
+			if currentText.Origin == nil && closedByRow > 0 {
+				closedByRow--
+				continue
+			}
 			if currentText.Origin != nil {
 				moveCurrent(true)
 				continue
 			}
 			fallthrough
 		default:
-			if screening && stext == "," {
+			if screening && !escaping && stext == "," {
 				scanner.Scan()
-				text := scanner.Text()
-				screen, err := strconv.Atoi(text)
-				if err != nil {
-					logPieceError(err, stext, text)
+				screens, exclude := scanScreens(stext, scanner.Text())
+				if exclude {
+					currentText.NotScreens = append(currentText.NotScreens, screens...)
+				} else {
+					currentText.Screens = append(currentText.Screens, screens...)
 				}
-				currentText.Screens = append(currentText.Screens, uint(screen))
 			} else {
 				currentText.Text += stext
 			}
@@ -262,10 +818,123 @@ func (tp *TextParser) Scan(r io.Reader) []*TextPiece {
 	//Remove possible empty pieces.
 	var text2 []*TextPiece
 	for _, piece := range text {
-		if piece.Text != "" {
+		if piece.Text != "" || piece.Gap != 0 || piece.Spin || piece.Graph != nil {
 			text2 = append(text2, piece)
 		}
 	}
 
-	return text2
+	return text2, errs
+}
+
+// screenRange returns screens from first to last, in any order,
+// but no more than maxScreenRange of them.
+func screenRange(first, last int) []uint {
+	if last < first {
+		first, last = last, first
+	}
+	if last-first >= maxScreenRange {
+		logEvery(hotLogInterval, WARN, "Screen range `%d-%d` too long, using first %d screens", first, last, maxScreenRange)
+		last = first + maxScreenRange - 1
+	}
+	var screens []uint
+	for screen := first; screen <= last; screen++ {
+		screens = append(screens, uint(screen))
+	}
+	return screens
+}
+
+// parseScreens parses comma separated screens and ranges of screens
+// given in a single value, e.g. 0-2,-4, into screens to draw on
+// and screens to exclude, the same way {S reads them.
+func parseScreens(value string) (screens, notScreens []uint, err error) {
+	for _, part := range strings.Split(value, ",") {
+		exclude := strings.HasPrefix(part, "-")
+		part = strings.TrimPrefix(part, "-")
+		firstText, lastText := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			firstText, lastText = part[:i], strings.TrimPrefix(part[i+1:], "-")
+		}
+		first, err := strconv.Atoi(firstText)
+		if err != nil {
+			return screens, notScreens, err
+		}
+		last, err := strconv.Atoi(lastText)
+		if err != nil {
+			return screens, notScreens, err
+		}
+		if exclude {
+			notScreens = append(notScreens, screenRange(first, last)...)
+		} else {
+			screens = append(screens, screenRange(first, last)...)
+		}
+	}
+	return screens, notScreens, nil
+}
+
+// maxGraphValues is the maximum number of values in {GRAPH.
+const maxGraphValues = 1024
+
+// maxGraphSize is the maximum width and height of {GRAPH, in pixels,
+// as no window can be any bigger than that.
+const maxGraphSize = math.MaxInt16
+
+// parseGraph parses `<value>,<value>...[:<width>[:<height>]]` graph
+// definition. Width defaults to one pixel per value, height to 0.
+// Negative values are clamped to 0.
+func parseGraph(spec string) (values []float64, width, height int, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return nil, 0, 0, fmt.Errorf("too many graph dimensions in `%s`", spec)
+	}
+	list := strings.Split(parts[0], ",")
+	if len(list) > maxGraphValues {
+		return nil, 0, 0, fmt.Errorf("more than %d graph values", maxGraphValues)
+	}
+	for _, item := range list {
+		value, err := strconv.ParseFloat(item, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, 0, 0, fmt.Errorf("invalid graph value `%s`", item)
+		}
+		values = append(values, math.Max(value, 0))
+	}
+	width = len(values)
+	if len(parts) > 1 {
+		if width, err = strconv.Atoi(parts[1]); err != nil || width <= 0 || width > maxGraphSize {
+			return nil, 0, 0, fmt.Errorf("invalid graph width `%s`", parts[1])
+		}
+	}
+	if len(parts) > 2 {
+		if height, err = strconv.Atoi(parts[2]); err != nil || height < 0 || height > maxGraphSize {
+			return nil, 0, 0, fmt.Errorf("invalid graph height `%s`", parts[2])
+		}
+	}
+	return values, width, height, nil
+}
+
+// scanInlineValue reads inline value, e.g. font name or path with optional size,
+// piece name or copied text, starting with the first token.
+// Value ends at an unescaped space, which is consumed,
+// or at a bracket or newline, which is returned to be processed further.
+func scanInlineValue(scanner *bufio.Scanner, first string) (value, last string) {
+	escaping := false
+	stext := first
+	for {
+		switch {
+		case escaping:
+			value += stext
+			escaping = false
+		case stext == "\\":
+			escaping = true
+		case stext == " ":
+			return value, ""
+		case stext == "\n" || stext == "}" || stext[0] == '{':
+			return value, stext
+		default:
+			value += stext
+		}
+		if !scanner.Scan() {
+			return value, ""
+		}
+		stext = scanner.Text()
+	}
 }
@@ -37,20 +37,60 @@ var TokenizeTests = []struct {
 	tokenExpected   string
 }{
 	{"t", 1, "t"},
-	{"te", 1, "t"},
-	{"tes", 1, "t"},
-	{"test", 1, "t"},
+	{"te", 2, "te"},
+	{"tes", 3, "tes"},
+	{"test", 4, "test"},
+	{"test{F1", 4, "test"},
+	{"test}", 4, "test"},
+	{"te\\st", 2, "te"},
+	{"test,2", 4, "test"},
+	{"test\ntest", 4, "test"},
+	{"te st", 2, "te"},
+	{"t3st", 4, "t3st"},
+	{"}test", 1, "}"},
+	{",test", 1, ","},
+	{"\\test", 1, "\\"},
+	{" test", 1, " "},
+	{"{test", 1, "{"},
 	{"{Ftest", 2, "{F"},
 	{"{Stest", 2, "{S"},
 	{"{CFtest", 3, "{CF"},
 	{"{CBtest", 3, "{CB"},
 	{"{ARtest", 3, "{AR"},
+	{"{ACtest", 3, "{AC"},
+	{"{ALLtest", 4, "{ALL"},
+	{"{ALtest", 1, "{"},
+	{"{MW50test", 3, "{MW"},
+	{"{PX5test", 3, "{PX"},
+	{"{PILL10test", 5, "{PILL"},
+	{"{SPINtest", 5, "{SPIN"},
+	{"{SPtest", 2, "{S"},
+	{"{F:1:test", 2, "{F"},
+	{"{GRAPH1,2test", 6, "{GRAPH"},
+	{"{ROT90test", 4, "{ROT"},
+	{"{BOX1test", 4, "{BOX"},
+	{"{GAP5test", 4, "{GAP"},
+	{":1:test", 1, ":"},
+	{"test1:test2", 5, "test1"},
+	{"{URGENTtest", 7, "{URGENT"},
+	{"{COPY10.0.0.1 test", 5, "{COPY"},
+	{"{COL1test", 4, "{COL"},
+	{"{CO0xFFFFFFFFtest", 3, "{CO"},
+	{"{PILtest", 1, "{"},
+	{"{X-50test", 2, "{X"},
+	{"{Z2test", 2, "{Z"},
+	{"{Nclock test", 2, "{N"},
+	{"{GAP10}", 4, "{GAP"},
 	{"0xff1eF09atest", 10, "0xff1eF09a"},
 	{"0xff1eF0test", 1, "0"},
 	{"0312495test", 7, "0312495"},
 	{"5942130", 7, "5942130"},
 }
 
+func intPtr(i int) *int {
+	return &i
+}
+
 func assertEqual(t *testing.T, input, expected, actual interface{}, name string, i int) {
 	if !reflect.DeepEqual(expected, actual) {
 		t.Errorf("%s:%d(%v) == %v != %v\n", name, i, input, actual, expected)
@@ -126,6 +166,39 @@ var ScanTests = []struct {
 	{"{S1,2test}", []*TextPiece{
 		{Text: "test", Screens: []uint{1, 2}},
 	}},
+	{"{S1-3test}", []*TextPiece{
+		{Text: "test", Screens: []uint{1, 2, 3}},
+	}},
+	{"{S-1-3test}", []*TextPiece{
+		{Text: "test", NotScreens: []uint{1, 2, 3}},
+	}},
+	{"{S0,2-3test}", []*TextPiece{
+		{Text: "test", Screens: []uint{0, 2, 3}},
+	}},
+	{"{S3-1test}", []*TextPiece{
+		{Text: "test", Screens: []uint{1, 2, 3}},
+	}},
+	{"{S1,-2test}", []*TextPiece{
+		{Text: "test", Screens: []uint{1}, NotScreens: []uint{2}},
+	}},
+	{"{S1-test}", []*TextPiece{
+		{Text: "-test", Screens: []uint{1}},
+	}},
+	{"{S1-2{S4-5test}}", []*TextPiece{
+		{Text: "test", Screens: []uint{1, 2, 4, 5}},
+	}},
+	{"{ALLtest}", []*TextPiece{
+		{Text: "test"},
+	}},
+	{"{S1test1{ALL|}test2}", []*TextPiece{
+		{Text: "test1", Screens: []uint{1}}, {Text: "|"}, {Text: "test2", Screens: []uint{1}},
+	}},
+	{"{S-1test1{ALL|{S2test2}}test3}", []*TextPiece{
+		{Text: "test1", NotScreens: []uint{1}}, {Text: "|"}, {Text: "test2", Screens: []uint{2}}, {Text: "test3", NotScreens: []uint{1}},
+	}},
+	{"{S1{ALL,test}}", []*TextPiece{
+		{Text: ",test"},
+	}},
 	{"{F1test1}test2", []*TextPiece{
 		{Text: "test1", Font: 1}, {Text: "test2"},
 	}},
@@ -197,12 +270,298 @@ var ScanTests = []struct {
 	{"{F1{S2test1}test2}test3", []*TextPiece{
 		{Text: "test1", Font: 1, Screens: []uint{2}}, {Text: "test2", Font: 1}, {Text: "test3"},
 	}},
+	{"{S1test\\,more}", []*TextPiece{
+		{Text: "test,more", Screens: []uint{1}},
+	}},
+	{"{S1,2test\\,more}", []*TextPiece{
+		{Text: "test,more", Screens: []uint{1, 2}},
+	}},
+	{"{S1test\\,}", []*TextPiece{
+		{Text: "test,", Screens: []uint{1}},
+	}},
+	{"{MW50test}", []*TextPiece{
+		{Text: "test", MinWidth: 50},
+	}},
+	{"{AR{MW50test}}", []*TextPiece{
+		{Text: "test", Align: RIGHT, MinWidth: 50},
+	}},
+	{"{MW50test1{F1test2}}", []*TextPiece{
+		{Text: "test1", MinWidth: 50}, {Text: "test2", Font: 1},
+	}},
+	{"\\{MW50", []*TextPiece{
+		{Text: "{MW50"},
+	}},
+	{"{X100test}", []*TextPiece{
+		{Text: "test", AbsX: intPtr(100)},
+	}},
+	{"{X-50test}", []*TextPiece{
+		{Text: "test", AbsX: intPtr(-50)},
+	}},
+	{"{X50%test}", []*TextPiece{
+		{Text: "test", AbsX: intPtr(50), AbsXPercent: true},
+	}},
+	{"{X-25%%test}", []*TextPiece{
+		{Text: "%test", AbsX: intPtr(-25), AbsXPercent: true},
+	}},
+	{"{X50%}{X10 test}", []*TextPiece{
+		{Text: " test", AbsX: intPtr(10)},
+	}},
+	{"{X100test1{F1test2}test3}", []*TextPiece{
+		{Text: "test1", AbsX: intPtr(100)}, {Text: "test2", Font: 1}, {Text: "test3"},
+	}},
+	{"test1{ACtest2{F1test3}}test4", []*TextPiece{
+		{Text: "test1"}, {Text: "test2", Align: CENTER}, {Text: "test3", Align: CENTER, Font: 1}, {Text: "test4"},
+	}},
+	{"{COL1test1{F1test2}}{COL2test3}", []*TextPiece{
+		{Text: "test1", Column: intPtr(1)}, {Text: "test2", Font: 1}, {Text: "test3", Column: intPtr(2)},
+	}},
+	{"{Nclock test1{F1test2}}test3", []*TextPiece{
+		{Text: "test1", Name: "clock"}, {Text: "test2", Name: "clock", Font: 1}, {Text: "test3"},
+	}},
+	{"{Nmy\\ clock{F1test}}", []*TextPiece{
+		{Text: "test", Name: "my clock", Font: 1},
+	}},
+	{"{Z2{X10test1}{F1test2}}{X20test3}", []*TextPiece{
+		{Text: "test1", AbsX: intPtr(10), ZIndex: 2}, {Text: "test2", Font: 1, ZIndex: 2}, {Text: "test3", AbsX: intPtr(20)},
+	}},
+	{"{COPY10.0.0.1 ip{F1test}}test2", []*TextPiece{
+		{Text: "ip", Copy: "10.0.0.1"}, {Text: "test", Copy: "10.0.0.1", Font: 1}, {Text: "test2"},
+	}},
+	{"test1{SPIN}test2", []*TextPiece{
+		{Text: "test1"}, {Spin: true}, {Text: "test2"},
+	}},
+	{"{SPIN.oO{F1test}}", []*TextPiece{
+		{Text: ".oO", Spin: true}, {Text: "test", Font: 1},
+	}},
+	{"{URGENTtest1{F1test2}}test3", []*TextPiece{
+		{Text: "test1", Urgent: true}, {Text: "test2", Urgent: true, Font: 1}, {Text: "test3"},
+	}},
+	{"{PILL10test1{F1test2}}", []*TextPiece{
+		{Text: "test1", BlockHeight: 10}, {Text: "test2", BlockHeight: 10, Font: 1},
+	}},
+	{"{PX5test1{F1test2}test3}", []*TextPiece{
+		{Text: "test1", LeadPad: 5}, {Text: "test2", Font: 1}, {Text: "test3"},
+	}},
+	{"test1{GAP10}test2", []*TextPiece{
+		{Text: "test1"}, {Gap: 10}, {Text: "test2"},
+	}},
+	{"{GAP10test1{F1test2}}", []*TextPiece{
+		{Text: "test1", Gap: 10}, {Text: "test2", Font: 1},
+	}},
+	{"{S1{GAP5}}", []*TextPiece{
+		{Gap: 5, Screens: []uint{1}},
+	}},
 	{"{S-0test1}", []*TextPiece{
 		{Text: "test1", NotScreens: []uint{0}},
 	}},
 	{"{S-1test1}", []*TextPiece{
 		{Text: "test1", NotScreens: []uint{1}},
 	}},
+	{"{FDejaVu\\ Sans:10 test}", []*TextPiece{
+		{Text: "test", FontName: "DejaVu Sans:10"},
+	}},
+	{"{F/usr/share/fonts/font.ttf test1} test2", []*TextPiece{
+		{Text: "test1", FontName: "/usr/share/fonts/font.ttf"}, {Text: " test2"},
+	}},
+	{"{FMonospace {F1test1}test2}", []*TextPiece{
+		{Text: "test1", Font: 1}, {Text: "test2", FontName: "Monospace"},
+	}},
+	{"{FMonospace{ARtest}}", []*TextPiece{
+		{Text: "test", FontName: "Monospace", Align: RIGHT},
+	}},
+	{"{F1{CF0xFFFF0000{S2test}}}", []*TextPiece{
+		{Text: "test", Font: 1, Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}, Screens: []uint{2}},
+	}},
+	{"{S2{CF0xFFFF0000{F1test1}test2}test3}test4", []*TextPiece{
+		{Text: "test1", Font: 1, Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}, Screens: []uint{2}},
+		{Text: "test2", Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}, Screens: []uint{2}},
+		{Text: "test3", Screens: []uint{2}},
+		{Text: "test4"},
+	}},
+	{"{F1{CF0xFFFF0000{S2{CB0xFF0000FFtest1}test2}test3}test4}test5", []*TextPiece{
+		{Text: "test1", Font: 1, Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}, Background: &xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0x00, A: 0xFF}, Screens: []uint{2}},
+		{Text: "test2", Font: 1, Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}, Screens: []uint{2}},
+		{Text: "test3", Font: 1, Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}},
+		{Text: "test4", Font: 1},
+		{Text: "test5"},
+	}},
+	{"{S1,2,3{S4test1}{S5test2}}", []*TextPiece{
+		{Text: "test1", Screens: []uint{1, 2, 3, 4}}, {Text: "test2", Screens: []uint{1, 2, 3, 5}},
+	}},
+	{"{S-1,2{S-3test1}{S-4test2}}", []*TextPiece{
+		{Text: "test1", Screens: []uint{2}, NotScreens: []uint{1, 3}}, {Text: "test2", Screens: []uint{2}, NotScreens: []uint{1, 4}},
+	}},
+	{"{CF0xFFFF0000test1{CF-test2}test3}", []*TextPiece{
+		{Text: "test1", Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}},
+		{Text: "test2"},
+		{Text: "test3", Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}},
+	}},
+	{"{CB0xFF0000FF{CF0xFFFF0000{CB-test}}}", []*TextPiece{
+		{Text: "test", Foreground: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}},
+	}},
+	{"{CF-test}", []*TextPiece{
+		{Text: "test"},
+	}},
+	{"{CO0xFF000000test}", []*TextPiece{
+		{Text: "test", Outline: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}},
+	}},
+	{"{CO0xFF000000test1{CO-test2}}", []*TextPiece{
+		{Text: "test1", Outline: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}},
+		{Text: "test2"},
+	}},
+	{"{ARtest1{S1test2}test3}", []*TextPiece{
+		{Text: "test3", Align: RIGHT}, {Text: "test2", Align: RIGHT, Screens: []uint{1}}, {Text: "test1", Align: RIGHT},
+	}},
+	{"{AR{S-1test1}{S1test2}}", []*TextPiece{
+		{Text: "test2", Align: RIGHT, Screens: []uint{1}}, {Text: "test1", Align: RIGHT, NotScreens: []uint{1}},
+	}},
+	{"{FMonospace}test", []*TextPiece{
+		{Text: "test"},
+	}},
+	{"{F:1:2test}", []*TextPiece{
+		{Text: "2test", Font: 1},
+	}},
+	{"{F:DejaVu Sans\\:10:test}", []*TextPiece{
+		{Text: "test", FontName: "DejaVu Sans:10"},
+	}},
+	{"{CF:0xFF00AA33:test1}test2", []*TextPiece{
+		{Text: "test1", Foreground: &xgraphics.BGRA{B: 0x33, G: 0xAA, R: 0x00, A: 0xFF}}, {Text: "test2"},
+	}},
+	{"{S:0-1,-3:2,test}", []*TextPiece{
+		{Text: "2,test", Screens: []uint{0, 1}, NotScreens: []uint{3}},
+	}},
+	{"{S:-1-2:test}", []*TextPiece{
+		{Text: "test", NotScreens: []uint{1, 2}},
+	}},
+	{"{X:50%:%test}", []*TextPiece{
+		{Text: "%test", AbsX: intPtr(50), AbsXPercent: true},
+	}},
+	{"{N:clock:12:30}", []*TextPiece{
+		{Text: "12:30", Name: "clock"},
+	}},
+	{"{COPY:10.0.0.1:ip {F:1:test}}", []*TextPiece{
+		{Text: "ip ", Copy: "10.0.0.1"}, {Text: "test", Copy: "10.0.0.1", Font: 1},
+	}},
+	{"{GAP:5:}{PX:2:test}", []*TextPiece{
+		{Gap: 5}, {Text: "test", LeadPad: 2},
+	}},
+	{"{GRAPH1,2.5,-1:30:10 cpu}test", []*TextPiece{
+		{Text: "cpu", Graph: []float64{1, 2.5, 0}, GraphWidth: 30, GraphHeight: 10}, {Text: "test"},
+	}},
+	{"{GRAPH 1,2{F1test}}", []*TextPiece{
+		{Graph: []float64{1, 2}, GraphWidth: 2}, {Text: "test", Font: 1},
+	}},
+	{"{GRAPH1,x:5}test", []*TextPiece{
+		{Text: "{GRAPH1,x:5"}, {Text: "test"},
+	}},
+	{"{ROT90test1{F1test2}}{ROT-90test3}", []*TextPiece{
+		{Text: "test1", Rotate: 90}, {Text: "test2", Rotate: 90, Font: 1}, {Text: "test3", Rotate: 270},
+	}},
+	{"{CB0xFFFF0000{BOX2test1{F1test2}}}", []*TextPiece{
+		{Text: "test1", BoxBorder: 2, Background: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}},
+		{Text: "test2", BoxBorder: 2, Background: &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}, Font: 1},
+	}},
+	{"{BOX-1test}", []*TextPiece{
+		{Text: "{BOX-1"}, {Text: "test"},
+	}},
+	{"{ROT45test}", []*TextPiece{
+		{Text: "{ROT45"}, {Text: "test"},
+	}},
+	{"{F:1test}", []*TextPiece{
+		{Text: "{F:1test"},
+	}},
+}
+
+func TestParseGraph(t *testing.T) {
+	tests := []struct {
+		spec   string
+		values []float64
+		width  int
+		height int
+		err    error
+	}{
+		{"1,2,3", []float64{1, 2, 3}, 3, 0, nil},
+		{"1,-2:20", []float64{1, 0}, 20, 0, nil},
+		{"0.5:20:8", []float64{0.5}, 20, 8, nil},
+		{"", nil, 0, 0, errors.New("invalid graph value ``")},
+		{"1,NaN", nil, 0, 0, errors.New("invalid graph value `NaN`")},
+		{"1:0", nil, 0, 0, errors.New("invalid graph width `0`")},
+		{"1:2:-1", nil, 0, 0, errors.New("invalid graph height `-1`")},
+		{"1:32767:32767", []float64{1}, 32767, 32767, nil},
+		{"1:99999999", nil, 0, 0, errors.New("invalid graph width `99999999`")},
+		{"1:2:99999999", nil, 0, 0, errors.New("invalid graph height `99999999`")},
+		{"1:2:3:4", nil, 0, 0, errors.New("too many graph dimensions in `1:2:3:4`")},
+	}
+
+	for i, test := range tests {
+		values, width, height, err := parseGraph(test.spec)
+		assertEqual(t, test.spec, test.values, values, "ParseGraph", i)
+		assertEqual(t, test.spec, []int{test.width, test.height}, []int{width, height}, "ParseGraph", i)
+		assertEqualError(t, test.err, err, "ParseGraph", i)
+	}
+}
+
+func TestScanWithErrors_multiLine(t *testing.T) {
+	parser := NewTextParser()
+	parser.MultiLine = true
+
+	tests := []struct {
+		input  string
+		output []*TextPiece
+		errs   []string
+	}{
+		{"{F1test1}\ntest2", []*TextPiece{{Text: "test1", Font: 1}, {Text: "test2", Row: 1}}, nil},
+		{"{F1{CF0xFF000000test1\ntest2}}test3", []*TextPiece{
+			{Text: "test1", Font: 1, Foreground: NewBGRA(0xFF000000)}, {Text: "test2test3", Row: 1},
+		}, []string{"invalid `{{`: unclosed at the end of row 0"}},
+		{"{F1test1\n{F2test2}}\ntest3", []*TextPiece{
+			{Text: "test1", Font: 1}, {Text: "test2", Font: 2, Row: 1}, {Text: "test3", Row: 2},
+		}, []string{"invalid `{`: unclosed at the end of row 0"}},
+	}
+
+	for i, test := range tests {
+		actual, errs := parser.ScanWithErrors(strings.NewReader(test.input))
+		for _, t := range actual {
+			t.Origin = nil
+		}
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+
+		assertEqual(t, test.input, test.output, actual, "ScanWithErrors_multiLine", i)
+		assertEqual(t, test.input, test.errs, messages, "ScanWithErrors_multiLine", i)
+	}
+}
+
+func TestScanWithErrors(t *testing.T) {
+	parser := NewTextParser()
+
+	tests := []struct {
+		input  string
+		output []*TextPiece
+		errs   []string
+	}{
+		{"{F1test}", []*TextPiece{{Text: "test", Font: 1}}, nil},
+		{"{CFxtest1}{ROT45test2}", []*TextPiece{{Text: "{CFxtest1"}, {Text: "{ROT45"}, {Text: "test2"}}, []string{
+			"invalid `{CFxtest1`: strconv.ParseUint: parsing \"xtest1\": invalid syntax",
+			"invalid `{ROT45`: rotation must be a multiple of 90 degrees",
+		}},
+	}
+
+	for i, test := range tests {
+		actual, errs := parser.ScanWithErrors(strings.NewReader(test.input))
+		for _, t := range actual {
+			t.Origin = nil
+		}
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+
+		assertEqual(t, test.input, test.output, actual, "ScanWithErrors", i)
+		assertEqual(t, test.input, test.errs, messages, "ScanWithErrors", i)
+	}
 }
 
 func TestScan(t *testing.T) {
@@ -219,6 +578,50 @@ func TestScan(t *testing.T) {
 	}
 }
 
+var ScanMultiLineTests = []struct {
+	input    string
+	expected []*TextPiece
+}{
+	{"test1\ntest2", []*TextPiece{
+		{Text: "test1"}, {Text: "test2", Row: 1},
+	}},
+	{"{F1test1\ntest2}", []*TextPiece{
+		{Text: "test1", Font: 1}, {Text: "test2", Row: 1},
+	}},
+	{"{ARtest1}\n{F1test2}{ARtest3}", []*TextPiece{
+		{Text: "test1", Align: RIGHT}, {Text: "test2", Font: 1, Row: 1}, {Text: "test3", Align: RIGHT, Row: 1},
+	}},
+	{"test1\n\ntest3", []*TextPiece{
+		{Text: "test1"}, {Text: "test3", Row: 2},
+	}},
+}
+
+func TestScan_multiLine(t *testing.T) {
+	parser := NewTextParser()
+	parser.MultiLine = true
+
+	for i, tt := range ScanMultiLineTests {
+		actual := parser.Scan(strings.NewReader(tt.input))
+		for _, t := range actual {
+			t.Origin = nil
+		}
+
+		assertEqual(t, tt.input, tt.expected, actual, "Scan_multiLine", i)
+	}
+}
+
+func TestTokenize_multiLine(t *testing.T) {
+	parser := NewTextParser()
+	parser.MultiLine = true
+
+	input := "\ntest"
+	advance, token, err := parser.Tokenize([]byte(input), false)
+
+	assertEqual(t, input, 1, advance, "Tokenize_multiLine", 0)
+	assertEqual(t, input, []byte("\n"), token, "Tokenize_multiLine", 0)
+	assertEqualError(t, nil, err, "Tokenize_multiLine", 0)
+}
+
 func BenchmarkScan(b *testing.B) {
 	parser := NewTextParser()
 
@@ -226,3 +629,45 @@ func BenchmarkScan(b *testing.B) {
 		parser.Scan(strings.NewReader("{F1{S2test1}test2}test3"))
 	}
 }
+
+func BenchmarkScan_long(b *testing.B) {
+	parser := NewTextParser()
+	input := "{F1workspace: main} {CF0xFFFF0000load average 0.42 0.33 0.21}" +
+		"{ARbattery 97% charging} {AR{S1,2Wednesday, 1 January 2026 12:00:00}}"
+
+	for i := 0; i < b.N; i++ {
+		parser.Scan(strings.NewReader(input))
+	}
+}
+
+func TestTextPieceString(t *testing.T) {
+	tests := []struct {
+		input  *TextPiece
+		output string
+	}{
+		{&TextPiece{}, `""`},
+		{&TextPiece{Text: "te\"st"}, `"te\"st"`},
+		{&TextPiece{Text: "test", Font: 1, Align: RIGHT}, `"test" font=1 align=right`},
+		{&TextPiece{Text: "test", Font: 1, FontName: "DejaVu Sans:10"}, `"test" font="DejaVu Sans:10"`},
+		{&TextPiece{Text: "ip", Name: "net", Copy: "10.0.0.1"}, `"ip" name="net" copy="10.0.0.1"`},
+		{&TextPiece{
+			Text:       "test",
+			Foreground: &xgraphics.BGRA{B: 0x33, G: 0xAA, R: 0x00, A: 0xFF},
+			Background: &xgraphics.BGRA{B: 0xFF, G: 0x00, R: 0xAA, A: 0x33},
+			Screens:    []uint{1, 2},
+			NotScreens: []uint{0},
+		}, `"test" fg=0xFF00AA33 bg=0x33AA00FF screens=1,2 notscreens=0`},
+		{&TextPiece{
+			Text: "test", AbsX: intPtr(-50), Row: 1, MinWidth: 20, Bold: true, Italic: true, Underline: true,
+		}, `"test" x=-50 row=1 minwidth=20 bold italic underline`},
+		{&TextPiece{Text: "test", AbsX: intPtr(10), ZIndex: -1}, `"test" x=10 z=-1`},
+		{&TextPiece{Text: "cpu", Rotate: 90, Graph: []float64{1, 2.5}, GraphWidth: 4}, `"cpu" rotate=90 graph=1,2.5:4:0`},
+		{&TextPiece{Text: "tag", BlockHeight: 10, BoxBorder: 1}, `"tag" blockheight=10 box=1`},
+	}
+
+	for i, test := range tests {
+		actual := test.input.String()
+
+		assertEqual(t, test.input, test.output, actual, "TextPieceString", i)
+	}
+}
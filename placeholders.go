@@ -0,0 +1,170 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// placeholderInterval is how often lines with time placeholders
+// are substituted and drawn again.
+const placeholderInterval = time.Second
+
+// placeholderPattern matches {{NAME}} and {{NAME:argument}} placeholders.
+var placeholderPattern = regexp.MustCompile(`\{\{([A-Z]+)(?::([^}]*))?\}\}`)
+
+// timeNow returns the current time. Replaceable for testing.
+var timeNow = time.Now
+
+// placeholders return values of placeholders of given name,
+// for their argument.
+var placeholders = map[string]func(arg string) (string, error){
+	"TIME": func(layout string) (string, error) {
+		if layout == "" {
+			layout = "15:04"
+		}
+		return timeNow().Format(layout), nil
+	},
+	"ENV": func(name string) (string, error) {
+		return os.Getenv(name), nil
+	},
+	"HOSTNAME": func(string) (string, error) {
+		return os.Hostname()
+	},
+}
+
+// substitute replaces placeholders in line with their values.
+// Unknown placeholders are left as they are.
+func substitute(line string) string {
+	return placeholderPattern.ReplaceAllStringFunc(line, func(match string) string {
+		groups := placeholderPattern.FindStringSubmatch(match)
+		provider, ok := placeholders[groups[1]]
+		if !ok {
+			return match
+		}
+		value, err := provider(groups[2])
+		if err != nil {
+			logEvery(hotLogInterval, WARN, "Problem substituting `%s`: %s", match, err)
+		}
+		return value
+	})
+}
+
+// timed checks whether line has placeholders changing with time.
+func timed(line string) bool {
+	for _, groups := range placeholderPattern.FindAllStringSubmatch(line, -1) {
+		if groups[1] == "TIME" {
+			return true
+		}
+	}
+	return false
+}
+
+// partialParser is a Parser keeping pieces of earlier lines,
+// e.g. of other zones or screens, which a line does not replace.
+type partialParser interface {
+	Parser
+	// replaces checks whether line replaces pieces of earlier line.
+	replaces(line, earlier string) bool
+}
+
+// PlaceholderParser substitutes placeholders in text before scanning it
+// with Parser, and keeps the last text, so that its time placeholders
+// can be substituted again when time passes. With partialParser, all
+// the texts which pieces are still drawn are kept, e.g. of every zone.
+// It is safe for concurrent use, e.g. by many socket clients.
+type PlaceholderParser struct {
+	Parser Parser
+
+	mu sync.Mutex
+	// lines are the last texts, in order they were scanned in.
+	lines []string
+}
+
+// NewPlaceholderParser creates PlaceholderParser scanning substituted
+// text with parser.
+func NewPlaceholderParser(parser Parser) *PlaceholderParser {
+	return &PlaceholderParser{Parser: parser}
+}
+
+// Scan substitutes placeholders in text and scans it.
+func (pp *PlaceholderParser) Scan(r io.Reader) []*TextPiece {
+	return pp.scan(r, pp.Parser.Scan)
+}
+
+// ScanWithErrors works like Scan, but also returns problems
+// Parser found in the substituted text, if it reports them.
+func (pp *PlaceholderParser) ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError) {
+	var errs []ParseError
+	pieces := pp.scan(r, func(r io.Reader) (pieces []*TextPiece) {
+		pieces, errs = scanWithErrors(pp.Parser, r)
+		return pieces
+	})
+	return pieces, errs
+}
+
+// scan substitutes placeholders in text and scans it with scanText.
+func (pp *PlaceholderParser) scan(r io.Reader, scanText func(io.Reader) []*TextPiece) []*TextPiece {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logEvery(hotLogInterval, WARN, "Problem reading placeholder text: %s", err)
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	text := string(data)
+	partial, ok := pp.Parser.(partialParser)
+	lines := pp.lines[:0]
+	for _, line := range pp.lines {
+		if ok && !partial.replaces(text, line) {
+			lines = append(lines, line)
+		}
+	}
+	pp.lines = append(lines, text)
+	return scanText(strings.NewReader(substitute(text)))
+}
+
+// Rescan substitutes placeholders in the last scanned texts again
+// and scans them, in order they were scanned in, if any of them has
+// placeholders changing with time. Returns pieces of the last one.
+func (pp *PlaceholderParser) Rescan() ([]*TextPiece, bool) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	changing := false
+	for _, line := range pp.lines {
+		changing = changing || timed(line)
+	}
+	if !changing {
+		return nil, false
+	}
+	var pieces []*TextPiece
+	for _, line := range pp.lines {
+		pieces = pp.Parser.Scan(strings.NewReader(substitute(line)))
+	}
+	return pieces, true
+}
@@ -0,0 +1,113 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubstitute(t *testing.T) {
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return time.Date(2022, time.March, 7, 9, 5, 3, 0, time.UTC) }
+	t.Setenv("GOBAR_TEST", "value")
+	hostname, _ := os.Hostname()
+
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"test", "test"},
+		{"{{TIME}}", "09:05"},
+		{"{{TIME:15:04:05}} {{TIME:Jan 2}}", "09:05:03 Mar 7"},
+		{"{F1{{ENV:GOBAR_TEST}}}", "{F1value}"},
+		{"{{ENV:GOBAR_TEST_UNSET}}", ""},
+		{"{{HOSTNAME}}", hostname},
+		{"{{UNKNOWN:test}}", "{{UNKNOWN:test}}"},
+		{"{{time}}", "{{time}}"},
+	}
+
+	for i, test := range tests {
+		assertEqual(t, test.input, test.output, substitute(test.input), "Substitute", i)
+	}
+}
+
+func TestPlaceholderParserRescan(t *testing.T) {
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	now := time.Date(2022, time.March, 7, 9, 5, 3, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+
+	parser := NewPlaceholderParser(NewTextParser())
+	_, ok := parser.Rescan()
+	assertEqual(t, nil, false, ok, "PlaceholderParserRescan", 0)
+
+	texts := parser.Scan(strings.NewReader("{{TIME:15:04:05}}"))
+	assertEqual(t, nil, "09:05:03", texts[0].Text, "PlaceholderParserRescan", 1)
+
+	now = now.Add(time.Second)
+	texts, ok = parser.Rescan()
+	assertEqual(t, nil, true, ok, "PlaceholderParserRescan", 2)
+	assertEqual(t, nil, "09:05:04", texts[0].Text, "PlaceholderParserRescan", 2)
+
+	parser.Scan(strings.NewReader("{{ENV:USER}}"))
+	_, ok = parser.Rescan()
+	assertEqual(t, nil, false, ok, "PlaceholderParserRescan", 3)
+}
+
+func TestPlaceholderParserRescan_zones(t *testing.T) {
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	now := time.Date(2022, time.March, 7, 9, 5, 3, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+
+	tests := []struct {
+		parser Parser
+		inputs []string
+		output []string
+	}{
+		{NewZoneParser(NewTextParser()), []string{"left: {{TIME:15:04:05}}", "right: test"}, []string{"09:05:04", "test"}},
+		{NewZoneParser(NewTextParser()), []string{"left: {{TIME:15:04:05}}", "test"}, nil},
+		{NewZoneParser(NewTextParser()), []string{"{{TIME:15:04:05}}", "left: test"}, nil},
+		{NewZoneParser(NewTextParser()), []string{"left: {{TIME:15:04:05}}", "right: test", "right: test2"}, []string{"09:05:04", "test2"}},
+		{NewScreenParser(NewTextParser()), []string{"1|{{TIME:15:04:05}}", "0|test"}, []string{"test", "09:05:04"}},
+		{NewScreenParser(NewTextParser()), []string{"1|{{TIME:15:04:05}}", "1|test"}, nil},
+	}
+
+	for i, test := range tests {
+		now = time.Date(2022, time.March, 7, 9, 5, 3, 0, time.UTC)
+		parser := NewPlaceholderParser(test.parser)
+		for _, input := range test.inputs {
+			parser.Scan(strings.NewReader(input))
+		}
+
+		now = now.Add(time.Second)
+		texts, ok := parser.Rescan()
+		var output []string
+		for _, text := range texts {
+			output = append(output, text.Text)
+		}
+		assertEqual(t, test.inputs, test.output != nil, ok, "PlaceholderParserRescan_zones", i)
+		assertEqual(t, test.inputs, test.output, output, "PlaceholderParserRescan_zones", i)
+	}
+}
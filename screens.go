@@ -0,0 +1,133 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ScreenParser keeps pieces of every screen, so that input lines
+// in form of <screen>|<text> replace only text of that screen.
+// Lines without a screen replace text of all the screens
+// that have no lines of their own. Empty <screen>| line
+// makes that screen show these again.
+// It is safe for concurrent use, e.g. by many socket clients.
+type ScreenParser struct {
+	Parser Parser
+
+	mu      sync.Mutex
+	all     []*TextPiece
+	screens map[uint][]*TextPiece
+}
+
+// NewScreenParser creates ScreenParser scanning screens text with parser.
+func NewScreenParser(parser Parser) *ScreenParser {
+	return &ScreenParser{Parser: parser, screens: map[uint][]*TextPiece{}}
+}
+
+// Scan scans screen definition and returns pieces of all the screens.
+func (sp *ScreenParser) Scan(r io.Reader) []*TextPiece {
+	return sp.scan(r, sp.Parser.Scan)
+}
+
+// ScanWithErrors works like Scan, but also returns problems
+// Parser found in the screen text, if it reports them.
+func (sp *ScreenParser) ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError) {
+	var errs []ParseError
+	pieces := sp.scan(r, func(r io.Reader) (pieces []*TextPiece) {
+		pieces, errs = scanWithErrors(sp.Parser, r)
+		return pieces
+	})
+	return pieces, errs
+}
+
+// scan scans screen definition, with screen text scanned by scanText.
+func (sp *ScreenParser) scan(r io.Reader, scanText func(io.Reader) []*TextPiece) []*TextPiece {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logEvery(hotLogInterval, WARN, "Problem reading screen text: %s", err)
+	}
+	screen, text, ok := splitScreen(string(data))
+	pieces := scanText(strings.NewReader(text))
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	switch {
+	case !ok:
+		sp.all = pieces
+	case text == "":
+		delete(sp.screens, screen)
+	default:
+		// Pieces are copied, as parsers may hand the same pieces out again.
+		own := make([]*TextPiece, 0, len(pieces))
+		for _, piece := range pieces {
+			p := *piece
+			p.Screens = []uint{screen}
+			p.NotScreens = nil
+			own = append(own, &p)
+		}
+		sp.screens[screen] = own
+	}
+
+	screens := make([]uint, 0, len(sp.screens))
+	for screen := range sp.screens {
+		screens = append(screens, screen)
+	}
+	sort.Slice(screens, func(i, j int) bool { return screens[i] < screens[j] })
+
+	// Pieces for all the screens are copied, as screens
+	// they exclude change with lines for other screens.
+	all := make([]*TextPiece, 0, len(sp.all))
+	for _, piece := range sp.all {
+		excluding := *piece
+		excluding.NotScreens = append(append([]uint(nil), piece.NotScreens...), screens...)
+		all = append(all, &excluding)
+	}
+	for _, screen := range screens {
+		all = append(all, sp.screens[screen]...)
+	}
+	return all
+}
+
+// replaces checks whether line replaces pieces of earlier line,
+// i.e. both have the same screen, or neither has one.
+func (sp *ScreenParser) replaces(line, earlier string) bool {
+	screen, _, ok := splitScreen(line)
+	earlierScreen, _, earlierOk := splitScreen(earlier)
+	return ok == earlierOk && screen == earlierScreen
+}
+
+// splitScreen splits line into its screen and text.
+// Lines without a valid screen are all text.
+func splitScreen(line string) (uint, string, bool) {
+	prefix, text, ok := strings.Cut(line, "|")
+	screen, err := strconv.ParseUint(prefix, 10, 0)
+	if !ok || err != nil {
+		return 0, line, false
+	}
+	return uint(screen), text, true
+}
@@ -0,0 +1,81 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreenParser(t *testing.T) {
+	tests := []struct {
+		input  string
+		output []*TextPiece
+	}{
+		{"test", []*TextPiece{{Text: "test"}}},
+		{"1|test1", []*TextPiece{
+			{Text: "test", NotScreens: []uint{1}}, {Text: "test1", Screens: []uint{1}},
+		}},
+		{"0|{F1test0}", []*TextPiece{
+			{Text: "test", NotScreens: []uint{0, 1}},
+			{Text: "test0", Font: 1, Screens: []uint{0}}, {Text: "test1", Screens: []uint{1}},
+		}},
+		{"{S-2test2}", []*TextPiece{
+			{Text: "test2", NotScreens: []uint{2, 0, 1}},
+			{Text: "test0", Font: 1, Screens: []uint{0}}, {Text: "test1", Screens: []uint{1}},
+		}},
+		{"1|{S2test3}", []*TextPiece{
+			{Text: "test2", NotScreens: []uint{2, 0, 1}},
+			{Text: "test0", Font: 1, Screens: []uint{0}}, {Text: "test3", Screens: []uint{1}},
+		}},
+		{"0|", []*TextPiece{
+			{Text: "test2", NotScreens: []uint{2, 1}}, {Text: "test3", Screens: []uint{1}},
+		}},
+		{"a|b", []*TextPiece{
+			{Text: "a|b", NotScreens: []uint{1}}, {Text: "test3", Screens: []uint{1}},
+		}},
+	}
+
+	sp := NewScreenParser(NewTextParser())
+	for i, test := range tests {
+		output := sp.Scan(strings.NewReader(test.input))
+		// We don't care about Origin
+		for _, piece := range output {
+			piece.Origin = nil
+		}
+		assertEqual(t, test.input, test.output, output, "ScreenParser", i)
+	}
+}
+
+func TestScreenParser_cached(t *testing.T) {
+	// i3bar parser hands out its last pieces again for lines without status.
+	sp := NewScreenParser(&I3barParser{})
+	sp.Scan(strings.NewReader(`1|[{"full_text":"test"}]`))
+	output := sp.Scan(strings.NewReader("0|,"))
+
+	var screens [][]uint
+	for _, piece := range output {
+		screens = append(screens, piece.Screens)
+	}
+	assertEqual(t, "0|,", [][]uint{{0}, {1}}, screens, "ScreenParser_cached", -1)
+}
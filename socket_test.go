@@ -0,0 +1,64 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gobar.sock")
+	listener, err := listenSocket(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+
+	texts := make(chan []*TextPiece)
+	go serveSocket(listener, "\n", 1, NewTextParser(), texts)
+
+	for i, input := range []string{"test1", "test2"} {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.Write([]byte(input + "\n"))
+		assertEqualError(t, nil, err, "ServeSocket", i)
+		text := <-texts
+		assertEqual(t, input, []*TextPiece{{Text: input}}, text, "ServeSocket", i)
+		conn.Close()
+	}
+
+	_, err = listenSocket(path)
+	assertEqualError(t, fmt.Errorf("socket `%s` is already in use", path), err, "ServeSocket", 2)
+}
@@ -0,0 +1,69 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"github.com/jezek/xgbutil/xgraphics"
+)
+
+// pulseLevel returns how far into the urgent color pieces are at t,
+// rising from 0 to 1 and smoothly back over every period.
+// Without a period, they stay in the urgent color.
+func pulseLevel(t time.Time, period time.Duration) float64 {
+	if period <= 0 {
+		return 1
+	}
+	phase := float64(t.UnixNano()%int64(period)) / float64(period)
+	return (1 - math.Cos(2*math.Pi*phase)) / 2
+}
+
+// pulseRect blends every pixel of rect in img the given level
+// of the way into color, for urgent pieces drawn over whatever
+// is beneath them, e.g. the background image.
+func pulseRect(img *xgraphics.Image, rect image.Rectangle, color *xgraphics.BGRA, level float64) {
+	subimg, ok := img.SubImage(rect).(*xgraphics.Image)
+	if !ok || subimg == nil {
+		return
+	}
+	subimg.For(func(x, y int) xgraphics.BGRA {
+		beneath := subimg.At(x, y).(xgraphics.BGRA)
+		return *blendLevel(&beneath, color, level)
+	})
+}
+
+// blendLevel returns color the given level of the way from one to the other.
+func blendLevel(from, to *xgraphics.BGRA, level float64) *xgraphics.BGRA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*level))
+	}
+	return &xgraphics.BGRA{
+		B: mix(from.B, to.B),
+		G: mix(from.G, to.G),
+		R: mix(from.R, to.R),
+		A: mix(from.A, to.A),
+	}
+}
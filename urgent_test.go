@@ -0,0 +1,67 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jezek/xgbutil/xgraphics"
+)
+
+func TestPulseLevel(t *testing.T) {
+	tests := []struct {
+		t        time.Time
+		period   time.Duration
+		expected float64
+	}{
+		{time.Unix(0, 0), 4 * time.Second, 0},
+		{time.Unix(2, 0), 4 * time.Second, 1},
+		{time.Unix(4, 0), 4 * time.Second, 0},
+		{time.Unix(3, 0), 0, 1},
+	}
+
+	for i, test := range tests {
+		actual := pulseLevel(test.t, test.period)
+		assertEqual(t, test.t, test.expected, actual, "PulseLevel", i)
+	}
+}
+
+func TestBlendLevel(t *testing.T) {
+	black := &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x00, A: 0xFF}
+	red := &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0xFF, A: 0xFF}
+
+	tests := []struct {
+		level    float64
+		expected *xgraphics.BGRA
+	}{
+		{0, black},
+		{0.5, &xgraphics.BGRA{B: 0x00, G: 0x00, R: 0x80, A: 0xFF}},
+		{1, red},
+	}
+
+	for i, test := range tests {
+		actual := blendLevel(black, red, test.level)
+		assertEqual(t, test.level, test.expected, actual, "BlendLevel", i)
+	}
+}
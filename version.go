@@ -0,0 +1,70 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags "-X main.version=<version> -X main.commit=<commit>",
+// otherwise taken from the build info Go embeds into the binary.
+var (
+	version string
+	commit  string
+)
+
+// buildVersion returns version, commit and Go version gobar was built with,
+// filling whatever was not set at link time from the build info.
+func buildVersion() (string, string, string) {
+	ver, rev, goVersion := version, commit, runtime.Version()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if ver == "" {
+			ver = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && rev == "" {
+				rev = setting.Value
+			}
+		}
+		if info.GoVersion != "" {
+			goVersion = info.GoVersion
+		}
+	}
+	if ver == "" {
+		ver = "(devel)"
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	return ver, rev, goVersion
+}
+
+// printVersion writes gobar <version> (commit <commit>, <go version>) line to w.
+func printVersion(w io.Writer) error {
+	ver, rev, goVersion := buildVersion()
+	_, err := fmt.Fprintf(w, "gobar %s (commit %s, %s)\n", ver, rev, goVersion)
+	return err
+}
@@ -0,0 +1,85 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"container/list"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// widthCacheSize is the number of text widths remembered by Bar.
+const widthCacheSize = 256
+
+// widthKey identifies measured text.
+type widthKey struct {
+	face font.Face
+	text string
+	bold bool
+}
+
+type widthEntry struct {
+	key   widthKey
+	width fixed.Int26_6
+}
+
+// widthCache is a bounded, least recently used cache of text widths.
+// It is not safe for concurrent use.
+type widthCache struct {
+	size    int
+	entries map[widthKey]*list.Element
+	order   *list.List
+}
+
+// newWidthCache creates widthCache remembering up to size widths.
+func newWidthCache(size int) *widthCache {
+	return &widthCache{
+		size:    size,
+		entries: map[widthKey]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get returns width remembered for key, or measures it with measure
+// and remembers it, forgetting the least recently used one if full.
+func (c *widthCache) get(key widthKey, measure func() fixed.Int26_6) fixed.Int26_6 {
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*widthEntry).width
+	}
+	width := measure()
+	c.entries[key] = c.order.PushFront(&widthEntry{key, width})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*widthEntry).key)
+	}
+	return width
+}
+
+// clear forgets all remembered widths.
+func (c *widthCache) clear() {
+	c.entries = map[widthKey]*list.Element{}
+	c.order.Init()
+}
@@ -0,0 +1,65 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestWidthCacheGet(t *testing.T) {
+	tests := []struct {
+		text     string
+		measured bool
+	}{
+		{"test1", true},
+		{"test1", false},
+		{"test2", true},
+		{"test1", false},
+		// Cache is full, test2 is the least recently used.
+		{"test3", true},
+		{"test1", false},
+		{"test2", true},
+		{"test1", false},
+		{"test3", true},
+	}
+
+	cache := newWidthCache(2)
+	for i, test := range tests {
+		measured := false
+		key := widthKey{inconsolata.Regular8x16, test.text, false}
+		width := cache.get(key, func() fixed.Int26_6 {
+			measured = true
+			return fixed.I(len(test.text))
+		})
+
+		assertEqual(t, test.text, test.measured, measured, "WidthCacheGet", i)
+		assertEqual(t, test.text, fixed.I(len(test.text)), width, "WidthCacheGet", i)
+	}
+
+	cache.clear()
+	assertEqual(t, nil, 0, cache.order.Len(), "WidthCacheGet", -1)
+	assertEqual(t, nil, 0, len(cache.entries), "WidthCacheGet", -1)
+}
@@ -0,0 +1,153 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// zones are names of input zones, in order they are drawn in,
+// together with alignment of their pieces.
+var zones = []struct {
+	name  string
+	align Align
+}{
+	{"left", LEFT},
+	{"center", CENTER},
+	{"right", RIGHT},
+}
+
+// ZoneParser keeps pieces of every zone, so that input lines
+// in form of <zone>: <text> replace only pieces of that zone.
+// Lines without a zone replace all the zones at once,
+// until the next line with a zone.
+// It is safe for concurrent use, e.g. by many socket clients.
+type ZoneParser struct {
+	Parser Parser
+
+	mu     sync.Mutex
+	pieces map[string][]*TextPiece
+}
+
+// NewZoneParser creates ZoneParser scanning zones text with parser.
+func NewZoneParser(parser Parser) *ZoneParser {
+	return &ZoneParser{Parser: parser, pieces: map[string][]*TextPiece{}}
+}
+
+// Scan scans zone definition and returns pieces of all the zones.
+func (zp *ZoneParser) Scan(r io.Reader) []*TextPiece {
+	return zp.scan(r, zp.Parser.Scan)
+}
+
+// ScanWithErrors works like Scan, but also returns problems
+// Parser found in the zone text, if it reports them.
+func (zp *ZoneParser) ScanWithErrors(r io.Reader) ([]*TextPiece, []ParseError) {
+	var errs []ParseError
+	pieces := zp.scan(r, func(r io.Reader) (pieces []*TextPiece) {
+		pieces, errs = scanWithErrors(zp.Parser, r)
+		return pieces
+	})
+	return pieces, errs
+}
+
+// scan scans zone definition, with zone text scanned by scanText.
+func (zp *ZoneParser) scan(r io.Reader, scanText func(io.Reader) []*TextPiece) []*TextPiece {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logEvery(hotLogInterval, WARN, "Problem reading zone text: %s", err)
+	}
+	zone, text, align := splitZone(string(data))
+	pieces := alignZone(scanText(strings.NewReader(text)), align)
+
+	zp.mu.Lock()
+	defer zp.mu.Unlock()
+	if zone == "" {
+		zp.pieces = map[string][]*TextPiece{"": pieces}
+	} else {
+		delete(zp.pieces, "")
+		zp.pieces[zone] = pieces
+	}
+	all := zp.pieces[""]
+	for _, z := range zones {
+		all = append(all, zp.pieces[z.name]...)
+	}
+	return all
+}
+
+// replaces checks whether line replaces pieces of earlier line,
+// i.e. it has no zone, or the same zone, or earlier has no zone.
+func (zp *ZoneParser) replaces(line, earlier string) bool {
+	zone, _, _ := splitZone(line)
+	earlierZone, _, _ := splitZone(earlier)
+	return zone == "" || earlierZone == "" || zone == earlierZone
+}
+
+// splitZone splits line into its zone, text, and alignment of the zone.
+// Lines without a known zone are all text, left aligned.
+func splitZone(line string) (string, string, Align) {
+	zone, text, ok := strings.Cut(line, ":")
+	align, known := zoneAlign(strings.TrimSpace(zone))
+	if !ok || !known {
+		zone, text, align = "", line, LEFT
+	}
+	return zone, strings.TrimPrefix(text, " "), align
+}
+
+// zoneAlign returns alignment of pieces in zone of given name,
+// or false if there is no such zone.
+func zoneAlign(name string) (Align, bool) {
+	for _, zone := range zones {
+		if zone.name == name {
+			return zone.align, true
+		}
+	}
+	return LEFT, false
+}
+
+// alignZone aligns pieces that do not set their own alignment.
+// These are copied, as parsers may hand the same pieces out again.
+// Right aligned pieces are drawn from right to left, so these
+// get reversed, to keep reading in the order they were written in.
+func alignZone(pieces []*TextPiece, align Align) []*TextPiece {
+	if align == LEFT {
+		return pieces
+	}
+	var aligned, flowing []*TextPiece
+	for _, piece := range pieces {
+		if piece.Align != LEFT {
+			aligned = append(aligned, piece)
+			continue
+		}
+		p := *piece
+		p.Align = align
+		flowing = append(flowing, &p)
+	}
+	if align == RIGHT {
+		for i, j := 0, len(flowing)-1; i < j; i, j = i+1, j-1 {
+			flowing[i], flowing[j] = flowing[j], flowing[i]
+		}
+	}
+	return append(aligned, flowing...)
+}
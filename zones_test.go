@@ -0,0 +1,82 @@
+// gobar
+//
+// Copyright (C) 2026 Karol 'Kenji Takahashi' Woźniak
+//
+// Permission is hereby granted, free of charge, to any person obtaining
+// a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense,
+// and/or sell copies of the Software, and to permit persons to whom the
+// Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included
+// in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+// EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES
+// OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+// IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+// DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+// TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZoneParser(t *testing.T) {
+	tests := []struct {
+		input  string
+		output []*TextPiece
+	}{
+		{"left: test1", []*TextPiece{{Text: "test1"}}},
+		{"right: test2{CB0xFF0000FFtest3}", []*TextPiece{
+			{Text: "test1"},
+			{Text: "test3", Align: RIGHT, Background: NewBGRA(0xFF0000FF)},
+			{Text: "test2", Align: RIGHT},
+		}},
+		{"center:test4\n", []*TextPiece{
+			{Text: "test1"},
+			{Text: "test4", Align: CENTER},
+			{Text: "test3", Align: RIGHT, Background: NewBGRA(0xFF0000FF)},
+			{Text: "test2", Align: RIGHT},
+		}},
+		{"left: test5", []*TextPiece{
+			{Text: "test5"},
+			{Text: "test4", Align: CENTER},
+			{Text: "test3", Align: RIGHT, Background: NewBGRA(0xFF0000FF)},
+			{Text: "test2", Align: RIGHT},
+		}},
+		{"test6: {ARtest7}", []*TextPiece{{Text: "test6: "}, {Text: "test7", Align: RIGHT}}},
+		{"right: test8", []*TextPiece{{Text: "test8", Align: RIGHT}}},
+	}
+
+	parser := NewZoneParser(NewTextParser())
+	for i, test := range tests {
+		actual := parser.Scan(strings.NewReader(test.input))
+		for _, piece := range actual {
+			piece.Origin = nil
+		}
+		assertEqual(t, test.input, test.output, actual, "ZoneParser", i)
+	}
+}
+
+func TestAlignZone(t *testing.T) {
+	tests := []struct {
+		input  Align
+		output []*TextPiece
+	}{
+		{LEFT, []*TextPiece{{Text: "test1"}, {Text: "test2", Align: RIGHT}, {Text: "test3"}}},
+		{CENTER, []*TextPiece{{Text: "test2", Align: RIGHT}, {Text: "test1", Align: CENTER}, {Text: "test3", Align: CENTER}}},
+		{RIGHT, []*TextPiece{{Text: "test2", Align: RIGHT}, {Text: "test3", Align: RIGHT}, {Text: "test1", Align: RIGHT}}},
+	}
+
+	for i, test := range tests {
+		pieces := []*TextPiece{{Text: "test1"}, {Text: "test2", Align: RIGHT}, {Text: "test3"}}
+		assertEqual(t, test.input, test.output, alignZone(pieces, test.input), "AlignZone", i)
+		assertEqual(t, test.input, LEFT, pieces[0].Align, "AlignZone", i)
+	}
+}